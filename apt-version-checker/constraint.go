@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"github.com/knqyf263/go-deb-version"
+	"regexp"
+	"strings"
+)
+
+var relationPattern = regexp.MustCompile(`^(<<|<=|>=|>>|!=|=|<|>)\s*(\S+)$`)
+
+// versionRelation is a single relation like ">= 2.4"
+type versionRelation struct {
+	Operator string
+	Version  version.Version
+}
+
+// constraint is a comma separated list of relations, all of them must hold
+type constraint []versionRelation
+
+// parseConstraint parses an expression like ">= 2.4, << 3", the dpkg
+// operators are used, < and > are strict like << and >>
+func parseConstraint(expr string) (constraint, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		expr = expr[1 : len(expr)-1]
+	}
+	var c constraint
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		match := relationPattern.FindStringSubmatch(part)
+		if match == nil {
+			return nil, fmt.Errorf("invalid version relation %q", part)
+		}
+		v, err := version.NewVersion(match[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in relation %q: %w", part, err)
+		}
+		c = append(c, versionRelation{Operator: match[1], Version: v})
+	}
+	return c, nil
+}
+
+func (c constraint) String() string {
+	relations := make([]string, 0, len(c))
+	for _, r := range c {
+		relations = append(relations, fmt.Sprintf("%s %s", r.Operator, r.Version.String()))
+	}
+	return strings.Join(relations, ", ")
+}
+
+// Check reports whether the version satisfies every relation
+func (c constraint) Check(v version.Version) bool {
+	for _, r := range c {
+		cmp := v.Compare(r.Version)
+		var ok bool
+		switch r.Operator {
+		case "<<", "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">=":
+			ok = cmp >= 0
+		case ">>", ">":
+			ok = cmp > 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"io"
+	"strings"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// multilineFields keep their line breaks, other continuation lines are folded
+var multilineFields = map[string]bool{
+	"Description": true,
+	"Conffiles":   true,
+	"MD5Sum":      true,
+	"SHA1":        true,
+	"SHA256":      true,
+}
+
+type Paragraph map[string]string
+
+// Decompress detects the compression of the stream by its magic bytes,
+// mirrors do not always send a proper Content-Type
+func Decompress(input io.Reader) (io.ReadCloser, error) {
+	reader := bufio.NewReader(input)
+	magic, err := reader.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(reader)
+	case bytes.HasPrefix(magic, xzMagic):
+		xzr, err := xz.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xzr), nil
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return io.NopCloser(bzip2.NewReader(reader)), nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return io.NopCloser(reader), nil
+}
+
+// ParseParagraphs reads a Debian control file and calls fn for every
+// paragraph, only one paragraph is held in memory
+func ParseParagraphs(input io.Reader, fn func(Paragraph) error) error {
+	reader := bufio.NewReader(input)
+	paragraph := make(Paragraph)
+	lastKey := ""
+	flush := func() error {
+		if len(paragraph) == 0 {
+			return nil
+		}
+		err := fn(paragraph)
+		paragraph = make(Paragraph)
+		lastKey = ""
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(line) > 0 {
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case len(trimmed) == 0:
+				if ferr := flush(); ferr != nil {
+					return ferr
+				}
+			case line[0] == ' ' || line[0] == '\t':
+				if len(lastKey) == 0 {
+					break
+				}
+				if multilineFields[lastKey] {
+					paragraph[lastKey] += "\n" + trimmed
+				} else {
+					paragraph[lastKey] += " " + trimmed
+				}
+			default:
+				if idx := strings.Index(trimmed, ":"); idx > 0 {
+					lastKey = trimmed[:idx]
+					paragraph[lastKey] = strings.TrimSpace(trimmed[idx+1:])
+				}
+			}
+		}
+		if err == io.EOF {
+			return flush()
+		}
+	}
+}
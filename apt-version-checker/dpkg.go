@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/knqyf263/go-deb-version"
+	"os"
+	"sort"
+	"strings"
+)
+
+type installedResult struct {
+	Installed  packageVersion `json:"installed"`
+	Candidate  string         `json:"candidate"`
+	Repository string         `json:"repository"`
+	Upgrade    bool           `json:"upgrade"`
+	Security   bool           `json:"security"`
+}
+
+type pendingSummary struct {
+	Upgrades int `json:"upgrades"`
+	Security int `json:"security"`
+}
+
+// isSecuritySuite reports whether the suite is a security update suite like
+// bookworm-security or jammy-security
+func isSecuritySuite(suite string) bool {
+	return strings.HasSuffix(strings.SplitN(suite, "/", 2)[0], "-security")
+}
+
+// ReadInstalled returns the installed packages of the dpkg status file,
+// packages which are only unpacked or removed with config files left are
+// skipped. Without watched packages every installed package is returned.
+func ReadInstalled(fileName string, watched watchList) (map[string]packageVersion, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer DeferClose(file, "Failed to close dpkg status file")
+
+	installed := make(map[string]packageVersion)
+	index := watched.Index()
+	err = ParseParagraphs(file, func(pkg Paragraph) error {
+		status := strings.Fields(pkg["Status"])
+		if len(status) != 3 || status[2] != "installed" {
+			return nil
+		}
+		if len(watched) > 0 && len(index.Find(pkg)) == 0 {
+			return nil
+		}
+		current, err := version.NewVersion(pkg["Version"])
+		if err != nil {
+			CheckErr(err, "Failed to parse installed version of "+pkg["Package"])
+			return nil
+		}
+		// multiarch packages are listed once per architecture
+		if v, ok := installed[pkg["Package"]]; !ok || current.GreaterThan(v.Version) {
+			installed[pkg["Package"]] = packageVersion{Version: current}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return installed, nil
+}
+
+// installedWatchList watches the installed packages by their exact name
+func installedWatchList(installed map[string]packageVersion) watchList {
+	names := make([]string, 0, len(installed))
+	for name := range installed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	watched := make(watchList, 0, len(names))
+	for _, name := range names {
+		watched = append(watched, watchedPackage{Pattern: name})
+	}
+	return watched
+}
+
+// CompareInstalled reports the candidate of every installed package
+func CompareInstalled(installed map[string]packageVersion, packages map[string]packageResult) (map[string]installedResult, pendingSummary) {
+	results := make(map[string]installedResult)
+	var summary pendingSummary
+	for name, current := range installed {
+		r := installedResult{Installed: current}
+		if candidate, ok := packages[name]; ok && len(candidate.Error) == 0 {
+			r.Candidate = candidate.Version.String()
+			r.Repository = candidate.Repository
+			r.Upgrade = candidate.Version.GreaterThan(current.Version)
+			r.Security = candidate.Security
+		}
+		if r.Upgrade {
+			summary.Upgrades++
+			if r.Security {
+				summary.Security++
+			}
+		}
+		results[name] = r
+	}
+	return results, summary
+}
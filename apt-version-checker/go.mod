@@ -3,6 +3,7 @@ module apt-version-checker
 go 1.22.9
 
 require (
+	github.com/klauspost/compress v1.18.0
 	github.com/knqyf263/go-deb-version v0.0.0-20241115132648-6f4aee6ccd23
 	github.com/stapelberg/godebiancontrol v0.0.0-20180408134423-8c93e189186a
 	github.com/ulikunitz/xz v0.5.12
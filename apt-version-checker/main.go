@@ -2,19 +2,16 @@ package main
 
 import (
 	"bufio"
-	"compress/gzip"
 	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
 	"github.com/knqyf263/go-deb-version"
-	"github.com/stapelberg/godebiancontrol"
-	"github.com/ulikunitz/xz"
 	"io"
 	"log"
-	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"time"
 )
 
 var (
@@ -23,6 +20,11 @@ var (
 	suite        string
 	component    string
 	architecture string
+	repositories repositoryList
+	watched      watchList
+	statusFile   string
+	compare      bool
+	discovery    bool
 )
 
 func FatalErr(err error, str string) {
@@ -41,15 +43,6 @@ func DeferClose(f io.Closer, str string) {
 	CheckErr(f.Close(), str)
 }
 
-func contains(s []string, e string) bool {
-	for _, a := range s {
-		if a == e {
-			return true
-		}
-	}
-	return false
-}
-
 type packageVersion struct {
 	version.Version
 }
@@ -62,17 +55,30 @@ func (p packageVersion) MarshalText() (text []byte, err error) {
 	return []byte(p.String()), nil
 }
 
-func DownloadPackageList() (resp *http.Response, err error) {
-	for _, filename := range []string{"Packages", "Packages.gz", "Packages.xz"} {
-		resp, err = http.Get(fmt.Sprintf("%s/dists/%s/%s/binary-%s/%s", url, suite, component, architecture, filename))
-		if err != nil {
-			return resp, err
-		}
-		if resp.StatusCode == 200 {
-			return resp, err
+type packageResult struct {
+	Version    packageVersion `json:"version"`
+	Repository string         `json:"repository"`
+	Security   bool           `json:"security"`
+	Patterns   []string       `json:"patterns"`
+	Constraint string         `json:"constraint,omitempty"`
+	Satisfied  bool           `json:"satisfied"`
+	Error      string         `json:"error,omitempty"`
+}
+
+type result struct {
+	Packages     map[string]packageResult   `json:"packages"`
+	Repositories []repositoryResult         `json:"repositories"`
+	Installed    map[string]installedResult `json:"installed,omitempty"`
+	Pending      *pendingSummary            `json:"pending,omitempty"`
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
 		}
 	}
-	return resp, errors.New(resp.Status)
+	return false
 }
 
 func ReadPackageNames(fileName string) (lines []string, err error) {
@@ -97,78 +103,166 @@ func ReadPackageNames(fileName string) (lines []string, err error) {
 	return
 }
 
-func getPackages() []string {
+type discoveryItem map[string]string
+
+type discoveryResult struct {
+	Data []discoveryItem `json:"data"`
+}
+
+// getWatched returns the packages of -name and -package, lines of a package
+// file may have a constraint. Invalid entries are returned as errors.
+func getWatched() (watchList, map[string]string) {
+	var entries []string
 	if strings.HasPrefix(packageNames, "/") {
 		if _, err := os.Stat(packageNames); err != nil {
 			FatalErr(err, "Failed to read package file")
 		}
 		packages, err := ReadPackageNames(packageNames)
 		FatalErr(err, "Failed to read package file")
-		return packages
+		entries = packages
+	} else if len(packageNames) > 0 {
+		entries = splitPackageNames(packageNames)
+	}
+	list := make(watchList, 0, len(entries)+len(watched))
+	invalid := make(map[string]string)
+	for _, entry := range entries {
+		if err := list.Set(entry); err != nil {
+			invalid[strings.TrimSpace(entry)] = err.Error()
+		}
 	}
-	return strings.Split(packageNames, ",")
+	return append(list, watched...), invalid
+}
+
+// checkConstraints adds the watched packages which are not found and
+// evaluates the constraints of every pattern matching the found ones
+func checkConstraints(packages map[string]packageResult, watched watchList) {
+	found := make(map[string]bool)
+	for _, p := range packages {
+		for _, pattern := range p.Patterns {
+			found[pattern] = true
+		}
+	}
+	for _, w := range watched {
+		if !found[w.Pattern] {
+			packages[w.Pattern] = packageResult{Patterns: []string{w.Pattern}, Error: "package not found"}
+		}
+	}
+	for name, p := range packages {
+		var constraints []string
+		for _, w := range watched {
+			if len(w.Constraint) == 0 || !contains(p.Patterns, w.Pattern) {
+				continue
+			}
+			constraints = append(constraints, w.Constraint.String())
+			if len(p.Error) == 0 && !w.Constraint.Check(p.Version.Version) {
+				p.Satisfied = false
+			}
+		}
+		p.Constraint = strings.Join(constraints, ", ")
+		packages[name] = p
+	}
+}
+
+func discoveryData(packages map[string]packageResult) discoveryResult {
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	d := discoveryResult{Data: make([]discoveryItem, 0, len(names))}
+	for _, name := range names {
+		d.Data = append(d.Data, discoveryItem{
+			"{#PACKAGE}":    name,
+			"{#PATTERN}":    strings.Join(packages[name].Patterns, ","),
+			"{#CONSTRAINT}": packages[name].Constraint,
+		})
+	}
+	return d
 }
 
 func main() {
-	flag.StringVar(&packageNames, "name", "", "comma separated list of package name to check")
-	flag.StringVar(&url, "url", "", "the base of the Debian distribution")
+	flag.StringVar(&packageNames, "name", "", "semicolon separated list of package names or patterns like linux-image-*, src:NAME or provides:NAME with an optional constraint like \"nginx >= 1.22, << 2; openssl\" (commas between names are accepted too), or a file with one \"PATTERN [CONSTRAINT]\" per line")
+	flag.StringVar(&url, "url", "", "the base of the Debian distribution, a http(s) or file:// url or a local mirror directory")
 	flag.StringVar(&suite, "suite", "", "the distribution is generally a suite name")
 	flag.StringVar(&component, "component", "main", "the component name")
 	flag.StringVar(&architecture, "architecture", "amd64", "package architecture")
+	flag.Var(&repositories, "repo", "repository as \"URL SUITE COMPONENT [ARCHITECTURE]\", can be repeated")
+	flag.BoolVar(&compare, "installed", false, "compare the installed versions with the repositories, all installed packages without -name")
+	flag.StringVar(&statusFile, "status", "/var/lib/dpkg/status", "dpkg status file")
+	flag.Var(&watched, "package", "package as \"PATTERN [CONSTRAINT]\" like \"nginx >= 1.22, << 2\", can be repeated")
+	flag.BoolVar(&discovery, "discovery", false, "print the low level discovery of the packages")
 	flag.Parse()
 	log.SetOutput(os.Stderr)
 
-	if len(url) == 0 || len(suite) == 0 || len(component) == 0 {
+	if len(url) > 0 && len(suite) > 0 && len(component) > 0 {
+		repositories = append(repositoryList{{URL: strings.TrimSuffix(url, "/"), Suite: suite, Component: component}}, repositories...)
+	}
+	for i := range repositories {
+		if len(repositories[i].Architecture) == 0 {
+			repositories[i].Architecture = architecture
+		}
+	}
+	if len(repositories) == 0 {
 		flag.Usage()
 		os.Exit(1)
 	}
-	packages := getPackages()
-	if len(packages) == 0 {
+	packages, invalid := getWatched()
+	query := packages
+	var installed map[string]packageVersion
+	if compare {
+		var err error
+		installed, err = ReadInstalled(statusFile, packages)
+		FatalErr(err, "Failed to read dpkg status file")
+		if len(packages) == 0 {
+			query = installedWatchList(installed)
+		}
+	}
+	if len(query) == 0 && len(invalid) == 0 {
 		fmt.Print("{}")
 		os.Exit(0)
 	}
 
-	resp, err := DownloadPackageList()
-	FatalErr(err, "Failed to download package list")
-	defer DeferClose(resp.Body, "Failed to close http response body")
-
-	// Check that the server actually sent compressed data
-	var reader io.Reader
-	switch resp.Header.Get("Content-Type") {
-	case "application/x-xz":
-		xzr, err := xz.NewReader(resp.Body)
-		FatalErr(err, "Failed to unpack package list")
-		//defer DeferClose(reader, "Failed to close gzip stream")
-		reader = io.Reader(xzr)
-	case "application/x-gzip":
-		gzr, err := gzip.NewReader(resp.Body)
-		FatalErr(err, "Failed to unpack package list")
-		defer DeferClose(gzr, "Failed to close gzip stream")
-		reader = io.Reader(gzr)
-	default:
-		reader = resp.Body
-	}
-	paragraphs, err := godebiancontrol.Parse(reader)
-	FatalErr(err, "Failed to parse package list")
-
-	versions := make(map[string]packageVersion)
-	for _, pkg := range paragraphs {
-		packageName := pkg["Package"]
-		if contains(packages, packageName) {
-			current, err := version.NewVersion(pkg["Version"])
-			FatalErr(err, "Failed to parse package version")
-
-			latest, ok := versions[packageName]
-			if ok {
-				if current.GreaterThan(latest.Version) {
-					versions[packageName] = packageVersion{Version: current}
+	now := time.Now()
+	output := result{Packages: make(map[string]packageResult), Repositories: make([]repositoryResult, 0, len(repositories))}
+	for _, r := range repositories {
+		versions, status := ReadRepository(r, query, now)
+		if len(status.Error) > 0 {
+			log.Printf("%s: %s", r, status.Error)
+		}
+		output.Repositories = append(output.Repositories, status)
+		security := isSecuritySuite(r.Suite)
+		for name, current := range versions {
+			latest, ok := output.Packages[name]
+			switch {
+			case len(current.Error) > 0:
+				if !ok {
+					output.Packages[name] = packageResult{Patterns: current.Patterns, Repository: status.Repository, Error: current.Error}
 				}
-			} else {
-				versions[packageName] = packageVersion{Version: current}
+			case !ok || len(latest.Error) > 0 || current.Version.GreaterThan(latest.Version.Version):
+				output.Packages[name] = packageResult{Version: current.Version, Repository: status.Repository, Security: security, Patterns: current.Patterns, Satisfied: true}
+			case security && current.Version.Equal(latest.Version.Version):
+				// the same version is in the security suite too
+				latest.Security = true
+				output.Packages[name] = latest
 			}
 		}
 	}
-	d, err := json.Marshal(versions)
+	checkConstraints(output.Packages, packages)
+	for entry, err := range invalid {
+		output.Packages[entry] = packageResult{Patterns: []string{entry}, Error: err}
+	}
+	if discovery {
+		d, err := json.Marshal(discoveryData(output.Packages))
+		FatalErr(err, "Failed to marshal discovery data")
+		fmt.Print(string(d))
+		return
+	}
+	if compare {
+		var summary pendingSummary
+		output.Installed, summary = CompareInstalled(installed, output.Packages)
+		output.Pending = &summary
+	}
+	d, err := json.Marshal(output)
 	FatalErr(err, "Failed to marshal data")
 	fmt.Print(string(d))
 }
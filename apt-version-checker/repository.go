@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/knqyf263/go-deb-version"
+	"github.com/stapelberg/godebiancontrol"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxClockSkew is the tolerance of the Date field of a Release file
+const maxClockSkew = 5 * time.Minute
+
+var indexFiles = []string{"Packages.xz", "Packages.zst", "Packages.gz", "Packages.bz2", "Packages"}
+
+type repository struct {
+	URL          string
+	Suite        string
+	Component    string
+	Architecture string
+}
+
+func (r repository) String() string {
+	return fmt.Sprintf("%s %s %s %s", r.URL, r.Suite, r.Component, r.Architecture)
+}
+
+// repositoryList is the value of the repeatable -repo flag
+type repositoryList []repository
+
+func (l *repositoryList) String() string {
+	repos := make([]string, 0, len(*l))
+	for _, r := range *l {
+		repos = append(repos, r.String())
+	}
+	return strings.Join(repos, ", ")
+}
+
+// Set parses "URL SUITE COMPONENT [ARCHITECTURE]", the default architecture
+// is filled in after the flags are parsed
+func (l *repositoryList) Set(value string) error {
+	fields := strings.Fields(value)
+	if len(fields) < 3 || len(fields) > 4 {
+		return errors.New("expected URL SUITE COMPONENT [ARCHITECTURE]")
+	}
+	r := repository{URL: strings.TrimSuffix(fields[0], "/"), Suite: fields[1], Component: fields[2]}
+	if len(fields) == 4 {
+		r.Architecture = fields[3]
+	}
+	*l = append(*l, r)
+	return nil
+}
+
+type indexFile struct {
+	Hash string
+	Size int64
+}
+
+type release struct {
+	Date       time.Time
+	ValidUntil time.Time
+	SHA256     map[string]indexFile
+}
+
+// repositoryPackage is the newest version of a package in one repository
+type repositoryPackage struct {
+	Version  packageVersion
+	Patterns []string
+	Error    string
+}
+
+type repositoryResult struct {
+	Repository string `json:"repository"`
+	Date       int64  `json:"date"`
+	ValidUntil int64  `json:"valid_until,omitempty"`
+	Index      string `json:"index"`
+	Packages   int    `json:"packages"`
+	Error      string `json:"error,omitempty"`
+}
+
+// fetch opens a http(s) url, a file:// url or a local path
+func fetch(url string) (io.ReadCloser, error) {
+	if strings.HasPrefix(url, "file://") || !strings.Contains(url, "://") {
+		return os.Open(strings.TrimPrefix(url, "file://"))
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		DeferClose(resp.Body, "Failed to close http response body")
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func parseReleaseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC1123, time.RFC1123Z} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid release time %q", value)
+}
+
+// FetchRelease reads the InRelease file, or the Release file if there is
+// none. The signature is stripped without verification.
+func FetchRelease(r repository) (*release, error) {
+	body, err := fetch(fmt.Sprintf("%s/dists/%s/InRelease", r.URL, r.Suite))
+	if err != nil {
+		body, err = fetch(fmt.Sprintf("%s/dists/%s/Release", r.URL, r.Suite))
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer DeferClose(body, "Failed to close release file")
+	var fields Paragraph
+	err = ParseParagraphs(godebiancontrol.PGPSignatureStripper(body), func(p Paragraph) error {
+		if fields == nil {
+			fields = p
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, errors.New("empty release file")
+	}
+	rel := &release{SHA256: make(map[string]indexFile)}
+	if rel.Date, err = parseReleaseTime(fields["Date"]); err != nil {
+		return nil, err
+	}
+	if value, ok := fields["Valid-Until"]; ok {
+		if rel.ValidUntil, err = parseReleaseTime(value); err != nil {
+			return nil, err
+		}
+	}
+	for _, line := range strings.Split(fields["SHA256"], "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		rel.SHA256[parts[2]] = indexFile{Hash: parts[0], Size: size}
+	}
+	return rel, nil
+}
+
+// Validate checks the freshness of the release, mirrors may serve an
+// expired copy
+func (rel *release) Validate(now time.Time) error {
+	if rel.Date.After(now.Add(maxClockSkew)) {
+		return fmt.Errorf("release date %s is in the future", rel.Date.UTC().Format(time.RFC1123))
+	}
+	if !rel.ValidUntil.IsZero() && rel.ValidUntil.Before(now) {
+		return fmt.Errorf("release expired at %s", rel.ValidUntil.UTC().Format(time.RFC1123))
+	}
+	return nil
+}
+
+// verifyingReader counts and hashes the downloaded index
+type verifyingReader struct {
+	reader io.Reader
+	hash   hash.Hash
+	size   int64
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.reader.Read(p)
+	v.size += int64(n)
+	v.hash.Write(p[:n])
+	return n, err
+}
+
+// Verify reads the rest of the index and compares it to the release entry
+func (v *verifyingReader) Verify(expected indexFile) error {
+	if _, err := io.Copy(io.Discard, v); err != nil {
+		return err
+	}
+	if v.size != expected.Size {
+		return fmt.Errorf("index size %d does not match release size %d", v.size, expected.Size)
+	}
+	if sum := fmt.Sprintf("%x", v.hash.Sum(nil)); sum != expected.Hash {
+		return fmt.Errorf("index sha256 %s does not match release sha256 %s", sum, expected.Hash)
+	}
+	return nil
+}
+
+// ReadRepository returns the newest version of the watched packages in one
+// repository, unparsable versions are reported per package
+func ReadRepository(r repository, watched watchList, now time.Time) (map[string]repositoryPackage, repositoryResult) {
+	result := repositoryResult{Repository: r.String()}
+	versions := make(map[string]repositoryPackage)
+	fail := func(err error) (map[string]repositoryPackage, repositoryResult) {
+		result.Error = err.Error()
+		return nil, result
+	}
+
+	rel, err := FetchRelease(r)
+	if err != nil {
+		return fail(fmt.Errorf("failed to read release file: %w", err))
+	}
+	result.Date = rel.Date.Unix()
+	if !rel.ValidUntil.IsZero() {
+		result.ValidUntil = rel.ValidUntil.Unix()
+	}
+	if err := rel.Validate(now); err != nil {
+		return fail(err)
+	}
+
+	var expected indexFile
+	var body io.ReadCloser
+	for _, filename := range indexFiles {
+		name := fmt.Sprintf("%s/binary-%s/%s", r.Component, r.Architecture, filename)
+		var ok bool
+		if expected, ok = rel.SHA256[name]; !ok {
+			continue
+		}
+		if body, err = fetch(fmt.Sprintf("%s/dists/%s/%s", r.URL, r.Suite, name)); err == nil {
+			result.Index = name
+			break
+		}
+	}
+	if body == nil {
+		if err == nil {
+			err = errors.New("no package index in release file")
+		}
+		return fail(fmt.Errorf("failed to download package list: %w", err))
+	}
+	defer DeferClose(body, "Failed to close package list")
+
+	verifier := &verifyingReader{reader: body, hash: sha256.New()}
+	reader, err := Decompress(verifier)
+	if err != nil {
+		return fail(fmt.Errorf("failed to unpack package list: %w", err))
+	}
+	defer DeferClose(reader, "Failed to close package list stream")
+	index := watched.Index()
+	err = ParseParagraphs(reader, func(pkg Paragraph) error {
+		patterns := index.Find(pkg)
+		if len(patterns) == 0 {
+			return nil
+		}
+		packageName := pkg["Package"]
+		latest, seen := versions[packageName]
+		current, err := version.NewVersion(pkg["Version"])
+		if err != nil {
+			if !seen {
+				versions[packageName] = repositoryPackage{Patterns: patterns, Error: fmt.Sprintf("failed to parse version %q: %s", pkg["Version"], err)}
+			}
+			return nil
+		}
+		if !seen || len(latest.Error) > 0 || current.GreaterThan(latest.Version.Version) {
+			versions[packageName] = repositoryPackage{Version: packageVersion{Version: current}, Patterns: patterns}
+		}
+		return nil
+	})
+	if err != nil {
+		return fail(fmt.Errorf("failed to parse package list: %w", err))
+	}
+	if err := verifier.Verify(expected); err != nil {
+		return fail(err)
+	}
+	result.Packages = len(versions)
+	return versions, result
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	sourcePrefix   = "src:"
+	providesPrefix = "provides:"
+)
+
+// watchedPackage is a package name or a shell pattern like linux-image-*,
+// src:NAME matches the binary packages of a source package and provides:NAME
+// the packages providing a virtual package
+type watchedPackage struct {
+	Pattern    string
+	Constraint constraint
+}
+
+// parseWatched parses "PATTERN [CONSTRAINT]"
+func parseWatched(value string) (watchedPackage, error) {
+	value = strings.TrimSpace(value)
+	pattern, expr := value, ""
+	if idx := strings.IndexAny(value, " \t("); idx > 0 {
+		pattern, expr = value[:idx], value[idx:]
+	}
+	if _, err := path.Match(strings.TrimPrefix(strings.TrimPrefix(pattern, sourcePrefix), providesPrefix), ""); err != nil {
+		return watchedPackage{}, fmt.Errorf("invalid package pattern %q: %w", pattern, err)
+	}
+	c, err := parseConstraint(expr)
+	if err != nil {
+		return watchedPackage{}, err
+	}
+	return watchedPackage{Pattern: pattern, Constraint: c}, nil
+}
+
+// relationNames returns the package names of a Source or Provides field,
+// versions and architecture qualifiers are dropped
+func relationNames(field string) []string {
+	var names []string
+	for _, entry := range strings.Split(field, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		names = append(names, strings.SplitN(fields[0], ":", 2)[0])
+	}
+	return names
+}
+
+func (w watchedPackage) match(names []string, pattern string) bool {
+	for _, name := range names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the paragraph of a package index or the dpkg
+// status file is watched
+func (w watchedPackage) Matches(pkg Paragraph) bool {
+	switch {
+	case strings.HasPrefix(w.Pattern, sourcePrefix):
+		source := relationNames(pkg["Source"])
+		if len(source) == 0 {
+			// the source package name is omitted if it equals the binary name
+			source = []string{pkg["Package"]}
+		}
+		return w.match(source, strings.TrimPrefix(w.Pattern, sourcePrefix))
+	case strings.HasPrefix(w.Pattern, providesPrefix):
+		return w.match(relationNames(pkg["Provides"]), strings.TrimPrefix(w.Pattern, providesPrefix))
+	}
+	return w.match([]string{pkg["Package"]}, w.Pattern)
+}
+
+// watchList is the value of the repeatable -package flag
+type watchList []watchedPackage
+
+func (l *watchList) String() string {
+	packages := make([]string, 0, len(*l))
+	for _, w := range *l {
+		packages = append(packages, strings.TrimSpace(w.Pattern+" "+w.Constraint.String()))
+	}
+	return strings.Join(packages, "; ")
+}
+
+func (l *watchList) Set(value string) error {
+	w, err := parseWatched(value)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, w)
+	return nil
+}
+
+// isExact reports whether the pattern is a plain package name
+func (w watchedPackage) isExact() bool {
+	return !strings.HasPrefix(w.Pattern, sourcePrefix) && !strings.HasPrefix(w.Pattern, providesPrefix) &&
+		!strings.ContainsAny(w.Pattern, "*?[\\")
+}
+
+// watchIndex looks up the plain package names in a map, only the real
+// patterns are matched against every paragraph
+type watchIndex struct {
+	exact    map[string][]string
+	patterns watchList
+}
+
+// Index returns the lookup of the watched packages
+func (l watchList) Index() *watchIndex {
+	index := &watchIndex{exact: make(map[string][]string)}
+	for _, w := range l {
+		if w.isExact() {
+			index.exact[w.Pattern] = append(index.exact[w.Pattern], w.Pattern)
+		} else {
+			index.patterns = append(index.patterns, w)
+		}
+	}
+	return index
+}
+
+// Find returns the patterns of the watched packages matching the paragraph
+func (i *watchIndex) Find(pkg Paragraph) []string {
+	patterns := append([]string(nil), i.exact[pkg["Package"]]...)
+	for _, w := range i.patterns {
+		if w.Matches(pkg) {
+			patterns = append(patterns, w.Pattern)
+		}
+	}
+	return patterns
+}
+
+// splitPackageNames splits the -name list on semicolons, a comma separates
+// the package names too unless a version relation follows it like in
+// "nginx >= 1.22, << 2"
+func splitPackageNames(value string) []string {
+	var entries []string
+	for _, group := range strings.Split(value, ";") {
+		parts := strings.Split(group, ",")
+		entry := parts[0]
+		for _, part := range parts[1:] {
+			if strings.IndexAny(strings.TrimSpace(part), "<>=!") == 0 {
+				entry += "," + part
+				continue
+			}
+			entries = append(entries, entry)
+			entry = part
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
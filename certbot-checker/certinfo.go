@@ -1,117 +1,129 @@
 package main
 
 import (
-	"crypto/sha1"
-	"crypto/sha256"
 	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
 	"errors"
 	"fmt"
+	"github.com/Elbandi/zabbix-checker/common/certinfo"
 	"github.com/urfave/cli/v2"
 	webcertificate "golang.zabbix.com/agent2/plugins/web/certificate"
-	"os"
-	"path/filepath"
 )
 
 const (
-	dateFormat = "Jan 02 15:04:05 2006 GMT"
+	stateOk    = "ok"
+	stateError = "error"
 )
 
 type LetsEncryptCert struct {
 	webcertificate.Output
-	Name string `json:"name"`
+	certinfo.ChainInfo
+	Name   string `json:"name"`
+	Layout string `json:"layout"`
+	Root   string `json:"root"`
+	State  string `json:"state"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Id returns the name qualified with the layout and the root, the names of
+// different roots may be the same
+func (c *LetsEncryptCert) Id() string {
+	return c.Layout + ":" + c.Root + ":" + c.Name
+}
+
+// HasName reports whether name is the id or the bare name of the certificate
+func (c *LetsEncryptCert) HasName(name string) bool {
+	return c.Id() == name || c.Name == name
 }
 
 var certInfoCommand = cli.Command{
-	Name:   "certinfo",
-	Usage:  "certinfo /etc/letsencrypt",
+	Name:  "certinfo",
+	Usage: "certinfo [--glob PATTERN] FILE|DIR|LAYOUT:/etc/letsencrypt...",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "glob",
+			Usage: "File name pattern of the certificates in the directories",
+			Value: "*",
+		},
+	},
 	Action: cmdCertInfo,
 }
 
-func getValidationResult(leaf *x509.Certificate, opts x509.VerifyOptions, subject, issuer string) webcertificate.ValidationResult {
-	var out webcertificate.ValidationResult
+// errorCert is the output entry of a certificate which could not be read
+func errorCert(name string, err error) LetsEncryptCert {
+	var o LetsEncryptCert
+	o.Name = name
+	o.State = stateError
+	o.Error = err.Error()
+	o.Result = webcertificate.ValidationResult{Value: stateError, Message: err.Error()}
+	return o
+}
 
-	if _, err := leaf.Verify(opts); err != nil {
-		if errors.As(err, &x509.UnknownAuthorityError{}) && subject == issuer {
-			out = webcertificate.ValidationResult{
-				Value:   "valid-but-self-signed",
-				Message: "certificate verified successfully, but determined to be self signed",
-			}
-		} else {
-			out = webcertificate.ValidationResult{Value: "invalid", Message: fmt.Sprintf("failed to verify certificate: %s", err.Error())}
+func readCertificate(ctx *cli.Context, source certSource, roots *x509.CertPool) LetsEncryptCert {
+	bundle, err := certinfo.ParseFile(source.File, ctx.String("password"))
+	if err != nil {
+		return errorCert(source.Name, err)
+	}
+	if len(source.Issuer) > 0 {
+		if issuer, err := certinfo.ParseFile(source.Issuer, ""); err == nil {
+			bundle.Certificates = append(bundle.Certificates, issuer.Certificates...)
 		}
-	} else {
-		out = webcertificate.ValidationResult{Value: "valid", Message: "certificate verified successfully"}
 	}
-
-	return out
+	cert, err := bundle.Leaf()
+	if err != nil {
+		return errorCert(source.Name, err)
+	}
+	var o LetsEncryptCert
+	o.Name = source.Name
+	o.State = stateOk
+	var chain []*x509.Certificate
+	o.Output, chain, err = certinfo.Inspect(bundle, x509.VerifyOptions{DNSName: certinfo.VerifyName(cert), Roots: roots})
+	if err != nil {
+		return errorCert(source.Name, err)
+	}
+	o.ChainInfo = certinfo.GetChainInfo(cert, chain)
+	if ctx.Bool("ocsp") {
+		o.Ocsp = certinfo.CheckOcsp(cert, certinfo.FindIssuer(cert, chain, bundle.Certificates[1:]), ctx.String("ocsp-url"))
+	}
+	return o
 }
 
-func cmdCertInfo(ctx *cli.Context) error {
-	if ctx.NArg() < 1 {
-		return errors.New("missing letsencrypt path")
+// loadCertificates reads every certificate of the roots, a broken
+// certificate is reported as an error entry. The roots without a LAYOUT:
+// prefix have the given layout, the glob is used by the file layout.
+func loadCertificates(ctx *cli.Context, paths []string, defaultLayout, glob string) ([]LetsEncryptCert, error) {
+	if len(paths) < 1 {
+		return nil, errors.New("missing letsencrypt path")
 	}
-	letsencryptPath := ctx.Args().First()
-	if stat, err := os.Stat(letsencryptPath); (err != nil) || !stat.IsDir() {
-		return errors.New("invalid letsencrypt path")
+	roots, err := certinfo.LoadTrustStore(ctx.String("ca-file"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trust store: %w", err)
 	}
 	output := make([]LetsEncryptCert, 0)
-	letsencryptLivePath := filepath.Join(letsencryptPath, "live")
-	err := filepath.Walk(letsencryptLivePath, func(path string, info os.FileInfo, err error) error {
+	for _, path := range paths {
+		layout, root := splitRoot(path, defaultLayout)
+		sources, err := findSources(root, layout, glob)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if !info.IsDir() {
-			return nil
+		for _, source := range sources {
+			c := readCertificate(ctx, source, roots)
+			c.Layout = layout
+			c.Root = root
+			output = append(output, c)
 		}
-		if letsencryptLivePath == path {
-			return nil
-		}
-		fullPath, err := filepath.Abs(path)
-		if err != nil {
-			return err
-		}
-		certPEM, err := os.ReadFile(filepath.Join(fullPath, "fullchain.pem"))
-		if err != nil {
-			return fmt.Errorf("failed to read certificate file: %w", err)
-		}
-		block, rest := pem.Decode(certPEM)
-		if block == nil {
-			return fmt.Errorf("failed to decode PEM block from file")
-		}
-		cert, err := x509.ParseCertificate(block.Bytes)
-		if err != nil {
-			return fmt.Errorf("failed to parse certificate: %w", err)
-		}
-		rootPool := x509.NewCertPool()
-		ok := rootPool.AppendCertsFromPEM(rest)
-		if !ok {
-			return fmt.Errorf("couldn't add pems from fullchain")
-		}
-		var o LetsEncryptCert
-		o.Name = info.Name()
-		o.X509 = webcertificate.Cert{
-			Version:            cert.Version,
-			Serial:             fmt.Sprintf("%x", cert.SerialNumber.Bytes()),
-			SignatureAlgorithm: cert.SignatureAlgorithm.String(),
-			Issuer:             cert.Issuer.ToRDNSequence().String(),
-			NotBefore:          webcertificate.CertTime{Value: cert.NotBefore.UTC().Format(dateFormat), Timestamp: cert.NotBefore.Unix()},
-			NotAfter:           webcertificate.CertTime{Value: cert.NotAfter.UTC().Format(dateFormat), Timestamp: cert.NotAfter.Unix()},
-			Subject:            cert.Subject.ToRDNSequence().String(),
-			PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
-			AlternativeNames:   cert.DNSNames,
-		}
-		o.Result = getValidationResult(
-			cert, x509.VerifyOptions{DNSName: cert.DNSNames[0], Roots: rootPool},
-			cert.Subject.ToRDNSequence().String(), cert.Issuer.ToRDNSequence().String(),
-		)
+	}
+	return output, nil
+}
 
-		o.Sha1Fingerprint = fmt.Sprintf("%x", sha1.Sum(cert.Raw))
-		o.Sha256Fingerprint = fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
-		output = append(output, o)
-		return nil
-	})
+// cmdCertInfo reads any certificate file or the matching files of a
+// directory, the layout roots need the LAYOUT: prefix or the --layout flag
+func cmdCertInfo(ctx *cli.Context) error {
+	layout := layoutFile
+	if ctx.IsSet("layout") {
+		layout = ctx.String("layout")
+	}
+	output, err := loadCertificates(ctx, ctx.Args().Slice(), layout, ctx.String("glob"))
 	if err != nil {
 		return err
 	}
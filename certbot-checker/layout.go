@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Elbandi/zabbix-checker/common/certinfo"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	layoutCertbot = "certbot"
+	layoutAcmeSh  = "acme.sh"
+	layoutLego    = "lego"
+	layoutFile    = "file"
+)
+
+var layouts = []string{layoutCertbot, layoutAcmeSh, layoutLego, layoutFile}
+
+// certSource is one certificate bundle found in a root directory
+type certSource struct {
+	Name   string
+	File   string
+	Issuer string
+}
+
+// splitRoot separates the optional "LAYOUT:" prefix of a root path
+func splitRoot(root, defaultLayout string) (string, string) {
+	for _, layout := range layouts {
+		if strings.HasPrefix(root, layout+":") {
+			return layout, strings.TrimPrefix(root, layout+":")
+		}
+	}
+	return defaultLayout, root
+}
+
+// findSources lists the certificate bundles of a root, only the first level
+// of the layout specific directory is searched. The file layout takes a
+// certificate file or the files of a directory matching the glob pattern.
+func findSources(root, layout, glob string) ([]certSource, error) {
+	if layout == layoutFile {
+		files, err := certinfo.FindFiles(root, glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s path: %s", layout, root)
+		}
+		sources := make([]certSource, 0, len(files))
+		for _, file := range files {
+			if certinfo.IsKeyFile(file) {
+				// key files next to the certificates
+				continue
+			}
+			sources = append(sources, certSource{Name: file, File: file})
+		}
+		return sources, nil
+	}
+	if stat, err := os.Stat(root); (err != nil) || !stat.IsDir() {
+		return nil, fmt.Errorf("invalid %s path: %s", layout, root)
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]certSource, 0)
+	switch layout {
+	case layoutCertbot:
+		// live/<name>/fullchain.pem
+		entries, err := os.ReadDir(filepath.Join(root, "live"))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			sources = append(sources, certSource{Name: entry.Name(), File: filepath.Join(root, "live", entry.Name(), "fullchain.pem")})
+		}
+	case layoutAcmeSh:
+		// <name>/fullchain.cer, ecc certificates are in <name>_ecc
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			file := filepath.Join(root, entry.Name(), "fullchain.cer")
+			if _, err := os.Stat(file); os.IsNotExist(err) {
+				// ca, deploy, dnsapi, etc.
+				continue
+			}
+			sources = append(sources, certSource{Name: entry.Name(), File: file})
+		}
+	case layoutLego:
+		// certificates/<name>.crt with the issuer in <name>.issuer.crt
+		files, err := filepath.Glob(filepath.Join(root, "certificates", "*.crt"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if strings.HasSuffix(file, ".issuer.crt") {
+				continue
+			}
+			name := strings.TrimSuffix(filepath.Base(file), ".crt")
+			sources = append(sources, certSource{Name: name, File: file, Issuer: filepath.Join(root, "certificates", name+".issuer.crt")})
+		}
+	default:
+		return nil, fmt.Errorf("unknown layout %s", layout)
+	}
+	return sources, nil
+}
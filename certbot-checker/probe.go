@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Elbandi/zabbix-checker/common/certinfo"
+	"github.com/urfave/cli/v2"
+	webcertificate "golang.zabbix.com/agent2/plugins/web/certificate"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type ProbeResult struct {
+	webcertificate.Output
+	Endpoint    string `json:"endpoint"`
+	ServerName  string `json:"server_name"`
+	Certificate string `json:"certificate"`
+	Match       bool   `json:"match"`
+	Protocol    string `json:"protocol"`
+	Cipher      string `json:"cipher"`
+	ChainLength int    `json:"chain_length"`
+	// Ocsp is the status of the stapled response
+	Ocsp  *certinfo.OcspResult `json:"ocsp,omitempty"`
+	State string               `json:"state"`
+	Error string               `json:"error,omitempty"`
+}
+
+var probeCommand = cli.Command{
+	Name:  "probe",
+	Usage: "probe --endpoint [tls|smtp|imap://]HOST:PORT[?sni=NAME&cert=NAME] [LAYOUT:]/etc/letsencrypt...",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "endpoint",
+			Aliases:  []string{"e"},
+			Usage:    "Endpoint to connect, smtp and imap use STARTTLS",
+			Required: true,
+		},
+		&cli.DurationFlag{
+			Name:    "timeout",
+			Aliases: []string{"t"},
+			Usage:   "Connection timeout",
+			Value:   10 * time.Second,
+		},
+	},
+	Action: cmdProbe,
+}
+
+// endpoint is a parsed --endpoint value
+type endpoint struct {
+	Scheme     string
+	Address    string
+	ServerName string
+	CertName   string
+}
+
+func parseEndpoint(value string) (endpoint, error) {
+	if !strings.Contains(value, "://") {
+		value = "tls://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return endpoint{}, err
+	}
+	switch u.Scheme {
+	case "tls", "smtp", "imap":
+	default:
+		return endpoint{}, fmt.Errorf("unknown endpoint scheme %s", u.Scheme)
+	}
+	if len(u.Port()) == 0 {
+		return endpoint{}, fmt.Errorf("missing port in endpoint %s", value)
+	}
+	e := endpoint{Scheme: u.Scheme, Address: u.Host, ServerName: u.Hostname(), CertName: u.Query().Get("cert")}
+	if sni := u.Query().Get("sni"); len(sni) > 0 {
+		e.ServerName = sni
+	}
+	return e, nil
+}
+
+// startImap sends the STARTTLS command after the greeting of the server
+func startImap(conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+	greeting, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("unexpected imap greeting: %s", strings.TrimSpace(greeting))
+	}
+	if _, err := conn.Write([]byte("a1 STARTTLS\r\n")); err != nil {
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "a1 ") {
+			if !strings.HasPrefix(line, "a1 OK") {
+				return fmt.Errorf("imap starttls failed: %s", strings.TrimSpace(line))
+			}
+			return nil
+		}
+	}
+}
+
+// handshake connects to the endpoint and returns the negotiated TLS state,
+// the certificate is verified later against the trust store
+func handshake(e endpoint, timeout time.Duration) (tls.ConnectionState, error) {
+	config := &tls.Config{ServerName: e.ServerName, InsecureSkipVerify: true}
+	conn, err := net.DialTimeout("tcp", e.Address, timeout)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	switch e.Scheme {
+	case "smtp":
+		client, err := smtp.NewClient(conn, e.ServerName)
+		if err != nil {
+			return tls.ConnectionState{}, err
+		}
+		if err := client.StartTLS(config); err != nil {
+			return tls.ConnectionState{}, err
+		}
+		state, _ := client.TLSConnectionState()
+		_ = client.Quit()
+		return state, nil
+	case "imap":
+		if err := startImap(conn); err != nil {
+			return tls.ConnectionState{}, err
+		}
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	return tlsConn.ConnectionState(), nil
+}
+
+// matchesName reports whether one of the alternative names covers the host,
+// wildcards match a single label
+func matchesName(names []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if name == host {
+			return true
+		}
+		if strings.HasPrefix(name, "*.") {
+			if idx := strings.Index(host, "."); idx > 0 && host[idx:] == name[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findLiveCert returns the on-disk certificate which should be served: the
+// one with the same fingerprint, the one given by name or the first one
+// covering the server name
+func findLiveCert(output []LetsEncryptCert, e endpoint, fingerprint string) *LetsEncryptCert {
+	for i, c := range output {
+		if c.State == stateOk && c.Sha256Fingerprint == fingerprint && (len(e.CertName) == 0 || c.HasName(e.CertName)) {
+			return &output[i]
+		}
+	}
+	for i, c := range output {
+		if c.State != stateOk {
+			continue
+		}
+		if len(e.CertName) > 0 {
+			if c.HasName(e.CertName) {
+				return &output[i]
+			}
+		} else if matchesName(c.X509.AlternativeNames, e.ServerName) {
+			return &output[i]
+		}
+	}
+	return nil
+}
+
+func probeEndpoint(value string, timeout time.Duration, roots *x509.CertPool, output []LetsEncryptCert) ProbeResult {
+	r := ProbeResult{Endpoint: value, State: stateError}
+	fail := func(err error) ProbeResult {
+		r.Error = err.Error()
+		r.Result = webcertificate.ValidationResult{Value: stateError, Message: err.Error()}
+		return r
+	}
+	e, err := parseEndpoint(value)
+	if err != nil {
+		return fail(err)
+	}
+	r.ServerName = e.ServerName
+	state, err := handshake(e, timeout)
+	if err != nil {
+		return fail(err)
+	}
+	if len(state.PeerCertificates) == 0 {
+		return fail(errors.New("no certificate served"))
+	}
+	r.Protocol = tls.VersionName(state.Version)
+	r.Cipher = tls.CipherSuiteName(state.CipherSuite)
+	r.ChainLength = len(state.PeerCertificates)
+	var chain []*x509.Certificate
+	r.Output, chain, err = certinfo.Inspect(&certinfo.Bundle{Certificates: state.PeerCertificates}, x509.VerifyOptions{DNSName: e.ServerName, Roots: roots})
+	if err != nil {
+		return fail(err)
+	}
+	// a self signed certificate is its own issuer
+	leaf := state.PeerCertificates[0]
+	r.Ocsp = certinfo.CheckStapledOcsp(state.OCSPResponse, leaf, certinfo.FindIssuer(leaf, chain, state.PeerCertificates))
+	r.State = stateOk
+	if live := findLiveCert(output, e, r.Sha256Fingerprint); live != nil {
+		r.Certificate = live.Id()
+		r.Match = live.Sha256Fingerprint == r.Sha256Fingerprint
+	}
+	return r
+}
+
+func cmdProbe(ctx *cli.Context) error {
+	output, err := loadCertificates(ctx, ctx.Args().Slice(), ctx.String("layout"), "*")
+	if err != nil {
+		return err
+	}
+	roots, err := certinfo.LoadTrustStore(ctx.String("ca-file"))
+	if err != nil {
+		return fmt.Errorf("failed to load trust store: %w", err)
+	}
+	results := make([]ProbeResult, 0)
+	for _, value := range ctx.StringSlice("endpoint") {
+		results = append(results, probeEndpoint(value, ctx.Duration("timeout"), roots, output))
+	}
+
+	b, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ocsp"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCipher = tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+
+// newTestCert returns a self signed certificate for the name and its PEM, it
+// is a CA to sign its own stapled ocsp response
+func newTestCert(t *testing.T, name string) (tls.Certificate, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// serveTls accepts connections until the listener is closed, the tls
+// listener is a tls.Listen one, the imap and smtp ones get a STARTTLS
+// exchange first
+func serveTls(listener net.Listener, config *tls.Config, protocol string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			switch protocol {
+			case "imap":
+				if !starttls(conn, "* OK IMAP4rev1 ready\r\n", map[string]string{"STARTTLS": "a1 OK Begin TLS negotiation now\r\n"}) {
+					return
+				}
+			case "smtp":
+				if !starttls(conn, "220 mx.example ESMTP\r\n", map[string]string{"EHLO": "250-mx.example\r\n250 STARTTLS\r\n", "STARTTLS": "220 Ready to start TLS\r\n"}) {
+					return
+				}
+				// the client greets again and quits over tls
+				tlsConn := tls.Server(conn, config)
+				reader := bufio.NewReader(tlsConn)
+				for _, reply := range []string{"250 mx.example\r\n", "221 Bye\r\n"} {
+					if _, err := reader.ReadString('\n'); err != nil {
+						return
+					}
+					if _, err := tlsConn.Write([]byte(reply)); err != nil {
+						return
+					}
+				}
+				return
+			default:
+				_ = conn.(*tls.Conn).Handshake()
+				return
+			}
+			_ = tls.Server(conn, config).Handshake()
+		}(conn)
+	}
+}
+
+// starttls sends the greeting and answers the commands by their first word
+// until the STARTTLS one
+func starttls(conn net.Conn, greeting string, replies map[string]string) bool {
+	if _, err := conn.Write([]byte(greeting)); err != nil {
+		return false
+	}
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return false
+		}
+		fields := strings.Fields(line)
+		var reply string
+		for _, field := range fields {
+			// the imap commands have a tag first
+			if r, ok := replies[strings.ToUpper(field)]; ok {
+				reply = r
+				break
+			}
+		}
+		if len(reply) == 0 {
+			return false
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return false
+		}
+		if strings.Contains(strings.ToUpper(line), "STARTTLS") {
+			return true
+		}
+	}
+}
+
+func TestProbeEndpoint(t *testing.T) {
+	served, servedPEM := newTestCert(t, "a.example")
+	other, _ := newTestCert(t, "b.example")
+	_, stalePEM := newTestCert(t, "b.example")
+
+	// the disk has the served certificate of a and an old one of b
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.pem"), servedPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.pem"), stalePEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := cli.NewContext(cli.NewApp(), flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	output, err := loadCertificates(ctx, []string{dir}, layoutFile, "*.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(output) != 2 {
+		t.Fatalf("got %d certificates, want 2", len(output))
+	}
+
+	// only a staples an ocsp response
+	staple, err := ocsp.CreateResponse(served.Leaf, served.Leaf, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: served.Leaf.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+	}, served.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	served.OCSPStaple = staple
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{served, other},
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{testCipher},
+	}
+	tlsListener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tlsListener.Close()
+	go serveTls(tlsListener, config, "tls")
+	imapListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imapListener.Close()
+	go serveTls(imapListener, config, "imap")
+	smtpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smtpListener.Close()
+	go serveTls(smtpListener, config, "smtp")
+
+	tests := []struct {
+		endpoint    string
+		serverName  string
+		certificate string
+		match       bool
+		ocsp        string
+	}{
+		{"tls://" + tlsListener.Addr().String() + "?sni=a.example", "a.example", output[0].Id(), true, "good"},
+		{"tls://" + tlsListener.Addr().String() + "?sni=b.example", "b.example", output[1].Id(), false, "unknown"},
+		{"imap://" + imapListener.Addr().String() + "?sni=a.example", "a.example", output[0].Id(), true, "good"},
+		{"smtp://" + smtpListener.Addr().String() + "?sni=b.example", "b.example", output[1].Id(), false, "unknown"},
+	}
+	for _, test := range tests {
+		r := probeEndpoint(test.endpoint, 5*time.Second, x509.NewCertPool(), output)
+		if r.State != stateOk {
+			t.Errorf("%s: state %s: %s", test.endpoint, r.State, r.Error)
+			continue
+		}
+		if r.ServerName != test.serverName || len(r.X509.AlternativeNames) != 1 || r.X509.AlternativeNames[0] != test.serverName {
+			t.Errorf("%s: served %v for %s, want %s", test.endpoint, r.X509.AlternativeNames, r.ServerName, test.serverName)
+		}
+		if r.Certificate != test.certificate || r.Match != test.match {
+			t.Errorf("%s: got certificate %q match %v, want %q match %v", test.endpoint, r.Certificate, r.Match, test.certificate, test.match)
+		}
+		if r.Ocsp == nil || r.Ocsp.Status != test.ocsp {
+			t.Errorf("%s: got stapled ocsp %+v, want %s", test.endpoint, r.Ocsp, test.ocsp)
+		}
+		if r.Protocol != "TLS 1.2" || r.Cipher != tls.CipherSuiteName(testCipher) {
+			t.Errorf("%s: got %s %s", test.endpoint, r.Protocol, r.Cipher)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Elbandi/zabbix-checker/common/certinfo"
+	"github.com/urfave/cli/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultRenewBeforeExpiry = 30 * 24 * time.Hour
+
+var (
+	liveFiles           = []string{"cert", "chain", "fullchain", "privkey"}
+	archiveFilePattern  = regexp.MustCompile(`^(cert|chain|fullchain|privkey)(\d+)\.pem$`)
+	renewBeforePattern  = regexp.MustCompile(`^(\d+)\s*(d|days?|w|weeks?)$`)
+	knownAuthenticators = []string{"webroot", "standalone", "nginx", "apache", "manual"}
+)
+
+type RenewalHealth struct {
+	Name               string   `json:"name"`
+	RenewalConfig      bool     `json:"renewal_config"`
+	Authenticator      string   `json:"authenticator"`
+	AuthenticatorValid bool     `json:"authenticator_valid"`
+	LiveVersion        int      `json:"live_version"`
+	ArchiveVersion     int      `json:"archive_version"`
+	LiveCurrent        bool     `json:"live_current"`
+	KeyMatch           bool     `json:"key_match"`
+	LastRenewal        int64    `json:"last_renewal"`
+	LastAttempt        int64    `json:"last_attempt"`
+	RenewalOverdue     bool     `json:"renewal_overdue"`
+	Errors             []string `json:"errors"`
+}
+
+var renewalCommand = cli.Command{
+	Name:  "renewal",
+	Usage: "renewal /etc/letsencrypt",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "log-file",
+			Usage: "Certbot log file, its modification time is the last renewal attempt",
+			Value: "/var/log/letsencrypt/letsencrypt.log",
+		},
+	},
+	Action: cmdRenewal,
+}
+
+// readRenewalConfig parses the configobj file of certbot into section/key
+// pairs, top level keys are in the "" section
+func readRenewalConfig(filename string) (map[string]map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config := map[string]map[string]string{"": {}}
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.Trim(line, "[]")
+			if _, ok := config[section]; !ok {
+				config[section] = map[string]string{}
+			}
+			continue
+		}
+		if idx := strings.Index(line, "="); idx != -1 {
+			config[section][strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+1:])
+		}
+	}
+	return config, scanner.Err()
+}
+
+func parseRenewBefore(value string) time.Duration {
+	m := renewBeforePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return defaultRenewBeforeExpiry
+	}
+	n, _ := strconv.Atoi(m[1])
+	if strings.HasPrefix(m[2], "w") {
+		n *= 7
+	}
+	return time.Duration(n) * 24 * time.Hour
+}
+
+func validAuthenticator(name string) bool {
+	if strings.HasPrefix(name, "dns-") {
+		return true
+	}
+	for _, a := range knownAuthenticators {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveVersions returns the newest version in archive/ and its modification time
+func archiveVersions(archivePath string) (int, int64, error) {
+	entries, err := os.ReadDir(archivePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	version := 0
+	var mtime int64
+	for _, entry := range entries {
+		m := archiveFilePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[2])
+		if n > version {
+			version = n
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().Unix() > mtime {
+			mtime = info.ModTime().Unix()
+		}
+	}
+	return version, mtime, nil
+}
+
+// liveVersion returns the archive version the live symlinks point at, the
+// links must agree on one version
+func liveVersion(livePath string) (int, error) {
+	version := 0
+	for _, name := range liveFiles {
+		target, err := os.Readlink(filepath.Join(livePath, name+".pem"))
+		if err != nil {
+			return 0, err
+		}
+		m := archiveFilePattern.FindStringSubmatch(filepath.Base(target))
+		if m == nil || m[1] != name {
+			return 0, fmt.Errorf("%s.pem points to unexpected file %s", name, target)
+		}
+		n, _ := strconv.Atoi(m[2])
+		if version != 0 && n != version {
+			return 0, fmt.Errorf("live links point to different versions %d and %d", version, n)
+		}
+		version = n
+	}
+	return version, nil
+}
+
+func checkRenewal(letsencryptPath, name string, lastAttempt int64, now time.Time) RenewalHealth {
+	h := RenewalHealth{Name: name, LastAttempt: lastAttempt, Errors: make([]string, 0)}
+	addError := func(err error) {
+		h.Errors = append(h.Errors, err.Error())
+	}
+	livePath := filepath.Join(letsencryptPath, "live", name)
+
+	renewBefore := defaultRenewBeforeExpiry
+	config, err := readRenewalConfig(filepath.Join(letsencryptPath, "renewal", name+".conf"))
+	if err != nil {
+		addError(err)
+	} else {
+		h.RenewalConfig = true
+		if v, ok := config[""]["renew_before_expiry"]; ok {
+			renewBefore = parseRenewBefore(v)
+		}
+		if params, ok := config["renewalparams"]; ok {
+			h.Authenticator = params["authenticator"]
+		}
+		h.AuthenticatorValid = validAuthenticator(h.Authenticator)
+		if !h.AuthenticatorValid {
+			addError(fmt.Errorf("invalid authenticator %q", h.Authenticator))
+		}
+	}
+
+	h.ArchiveVersion, h.LastRenewal, err = archiveVersions(filepath.Join(letsencryptPath, "archive", name))
+	if err != nil {
+		addError(err)
+	}
+	h.LiveVersion, err = liveVersion(livePath)
+	if err != nil {
+		addError(err)
+	}
+	h.LiveCurrent = h.LiveVersion > 0 && h.LiveVersion == h.ArchiveVersion
+
+	certPEM, err := os.ReadFile(filepath.Join(livePath, "fullchain.pem"))
+	if err != nil {
+		addError(err)
+		return h
+	}
+	// the expiry does not depend on the key, so a broken key still reports it
+	bundle, err := certinfo.Parse(certPEM, "")
+	if err != nil {
+		addError(err)
+	} else if leaf, err := bundle.Leaf(); err != nil {
+		addError(err)
+	} else {
+		h.RenewalOverdue = leaf.NotAfter.Sub(now) < renewBefore
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(livePath, "privkey.pem"))
+	if err != nil {
+		addError(err)
+		return h
+	}
+	if _, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		addError(err)
+		return h
+	}
+	h.KeyMatch = true
+	return h
+}
+
+func cmdRenewal(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return errors.New("missing letsencrypt path")
+	}
+	letsencryptPath := ctx.Args().First()
+	if stat, err := os.Stat(letsencryptPath); (err != nil) || !stat.IsDir() {
+		return errors.New("invalid letsencrypt path")
+	}
+	var lastAttempt int64
+	if stat, err := os.Stat(ctx.String("log-file")); err == nil {
+		lastAttempt = stat.ModTime().Unix()
+	}
+	entries, err := os.ReadDir(filepath.Join(letsencryptPath, "live"))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	output := make([]RenewalHealth, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		output = append(output, checkRenewal(letsencryptPath, entry.Name(), lastAttempt, now))
+	}
+
+	b, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Elbandi/zabbix-checker/common/lld"
+	"github.com/urfave/cli/v2"
+	"math"
+	"strings"
+	"time"
+)
+
+type certSummary struct {
+	Count          int    `json:"count"`
+	Errors         int    `json:"errors"`
+	Expiring       int    `json:"expiring"`
+	Expired        int    `json:"expired"`
+	Invalid        int    `json:"invalid"`
+	EarliestExpiry int64  `json:"earliest_expiry"`
+	EarliestName   string `json:"earliest_name"`
+}
+
+var discoveryCommand = cli.Command{
+	Name:   "discovery",
+	Usage:  "discovery [LAYOUT:]/etc/letsencrypt...",
+	Action: cmdDiscovery,
+}
+
+var expiryCommand = cli.Command{
+	Name:   "expiry",
+	Usage:  "expiry [LAYOUT:]/etc/letsencrypt... CERTNAME|NAME",
+	Action: cmdExpiry,
+}
+
+var validationCommand = cli.Command{
+	Name:   "validation",
+	Usage:  "validation [LAYOUT:]/etc/letsencrypt... CERTNAME|NAME",
+	Action: cmdValidation,
+}
+
+var summaryCommand = cli.Command{
+	Name:  "summary",
+	Usage: "summary [LAYOUT:]/etc/letsencrypt...",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "days",
+			Usage: "Count certificates expiring within this many days",
+			Value: 14,
+		},
+	},
+	Action: cmdSummary,
+}
+
+// daysUntilExpiry returns the whole days left, negative if expired
+func daysUntilExpiry(c LetsEncryptCert, now time.Time) int {
+	return int(math.Floor(time.Unix(c.X509.NotAfter.Timestamp, 0).Sub(now).Hours() / 24))
+}
+
+func findCertificate(ctx *cli.Context) (*LetsEncryptCert, error) {
+	if ctx.NArg() < 2 {
+		return nil, errors.New("missing certificate name")
+	}
+	args := ctx.Args().Slice()
+	output, err := loadCertificates(ctx, args[:len(args)-1], ctx.String("layout"), "*")
+	if err != nil {
+		return nil, err
+	}
+	// the qualified id of the discovery, or the bare name if it is unique
+	name := args[len(args)-1]
+	var found *LetsEncryptCert
+	for i, c := range output {
+		if c.Id() == name {
+			return &output[i], nil
+		}
+		if c.Name == name {
+			if found != nil {
+				return nil, fmt.Errorf("certificate %s is ambiguous", name)
+			}
+			found = &output[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("certificate %s not found", name)
+	}
+	return found, nil
+}
+
+func cmdDiscovery(ctx *cli.Context) error {
+	output, err := loadCertificates(ctx, ctx.Args().Slice(), ctx.String("layout"), "*")
+	if err != nil {
+		return err
+	}
+	d := make(lld.DiscoveryData, 0)
+	for _, c := range output {
+		item := make(lld.DiscoveryItem, 0)
+		item["CERTNAME"] = c.Id()
+		item["NAME"] = c.Name
+		item["LAYOUT"] = c.Layout
+		item["ROOT"] = c.Root
+		item["SAN"] = strings.Join(c.X509.AlternativeNames, ",")
+		d = append(d, item)
+	}
+	fmt.Println(d.JsonLine())
+	return nil
+}
+
+func cmdExpiry(ctx *cli.Context) error {
+	c, err := findCertificate(ctx)
+	if err != nil {
+		return err
+	}
+	if c.State == stateError {
+		return errors.New(c.Error)
+	}
+	fmt.Println(daysUntilExpiry(*c, time.Now()))
+	return nil
+}
+
+func cmdValidation(ctx *cli.Context) error {
+	c, err := findCertificate(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Println(c.Result.Value)
+	return nil
+}
+
+func cmdSummary(ctx *cli.Context) error {
+	output, err := loadCertificates(ctx, ctx.Args().Slice(), ctx.String("layout"), "*")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var summary certSummary
+	for _, c := range output {
+		summary.Count++
+		if c.State == stateError {
+			summary.Errors++
+			continue
+		}
+		days := daysUntilExpiry(c, now)
+		if days < 0 {
+			summary.Expired++
+		} else if days < ctx.Int("days") {
+			summary.Expiring++
+		}
+		if c.Result.Value == "invalid" {
+			summary.Invalid++
+		}
+		if summary.EarliestExpiry == 0 || c.X509.NotAfter.Timestamp < summary.EarliestExpiry {
+			summary.EarliestExpiry = c.X509.NotAfter.Timestamp
+			summary.EarliestName = c.Id()
+		}
+	}
+	b, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
@@ -0,0 +1,244 @@
+package certinfo
+
+// Certificate inspection shared by the certificate checkers, the output is
+// compatible with the web.certificate.get item of Zabbix agent 2.
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/pkcs12"
+	webcertificate "golang.zabbix.com/agent2/plugins/web/certificate"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const DateFormat = "Jan 02 15:04:05 2006 GMT"
+
+// Bundle is the content of a certificate file, the first certificate is the
+// leaf, the rest is the chain
+type Bundle struct {
+	Certificates []*x509.Certificate
+	Keys         []crypto.PrivateKey
+}
+
+// Parse decodes a PEM bundle, DER certificates or a PKCS#12 archive
+func Parse(data []byte, password string) (*Bundle, error) {
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		return parsePem(data)
+	}
+	if certs, err := x509.ParseCertificates(data); err == nil && len(certs) > 0 {
+		return &Bundle{Certificates: certs}, nil
+	}
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("unknown certificate format: %w", err)
+	}
+	var buf bytes.Buffer
+	for _, block := range blocks {
+		// ToPEM names every key "PRIVATE KEY" while the content is PKCS#1
+		// for rsa and SEC 1 for ec keys
+		if block.Type == "PRIVATE KEY" {
+			if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+				block.Type = "RSA PRIVATE KEY"
+			} else if _, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+				block.Type = "EC PRIVATE KEY"
+			}
+		}
+		if err := pem.Encode(&buf, &pem.Block{Type: block.Type, Bytes: block.Bytes}); err != nil {
+			return nil, err
+		}
+	}
+	return parsePem(buf.Bytes())
+}
+
+func parsePem(data []byte) (*Bundle, error) {
+	bundle := &Bundle{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate: %w", err)
+			}
+			bundle.Certificates = append(bundle.Certificates, cert)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			key, err := parsePrivateKey(block)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse private key: %w", err)
+			}
+			bundle.Keys = append(bundle.Keys, key)
+		}
+	}
+	return bundle, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.PrivateKey, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+// ParseFile reads and decodes a certificate file
+func ParseFile(file, password string) (*Bundle, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate '%s' file: %w", file, err)
+	}
+	bundle, err := Parse(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return bundle, nil
+}
+
+// Leaf returns the first certificate of the bundle
+func (b *Bundle) Leaf() (*x509.Certificate, error) {
+	if len(b.Certificates) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return b.Certificates[0], nil
+}
+
+// IsKeyFile reports whether the file has private keys but no certificate
+func IsKeyFile(file string) bool {
+	bundle, err := ParseFile(file, "")
+	return err == nil && len(bundle.Certificates) == 0 && len(bundle.Keys) > 0
+}
+
+// KeyMatches reports whether one of the private keys belongs to the certificate
+func (b *Bundle) KeyMatches(cert *x509.Certificate) bool {
+	for _, key := range b.Keys {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			continue
+		}
+		if public, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); ok && public.Equal(cert.PublicKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindFiles returns the path itself or the files of a directory matching the
+// glob pattern
+func FindFiles(path, glob string) ([]string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return []string{path}, nil
+	}
+	if len(glob) == 0 {
+		glob = "*"
+	}
+	matches, err := filepath.Glob(filepath.Join(path, glob))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if stat, err := os.Stat(match); err == nil && !stat.IsDir() {
+			files = append(files, match)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// VerifyName returns the name checked by the verification: the first DNS
+// name, the first IP address or nothing for legacy CN only certificates
+func VerifyName(cert *x509.Certificate) string {
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	if len(cert.IPAddresses) > 0 {
+		return cert.IPAddresses[0].String()
+	}
+	return ""
+}
+
+func AlternativeNames(cert *x509.Certificate) []string {
+	names := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses))
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
+
+func NewCert(cert *x509.Certificate) webcertificate.Cert {
+	return webcertificate.Cert{
+		Version:            cert.Version,
+		Serial:             fmt.Sprintf("%x", cert.SerialNumber.Bytes()),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		Issuer:             cert.Issuer.ToRDNSequence().String(),
+		NotBefore:          webcertificate.CertTime{Value: cert.NotBefore.UTC().Format(DateFormat), Timestamp: cert.NotBefore.Unix()},
+		NotAfter:           webcertificate.CertTime{Value: cert.NotAfter.UTC().Format(DateFormat), Timestamp: cert.NotAfter.Unix()},
+		Subject:            cert.Subject.ToRDNSequence().String(),
+		PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
+		AlternativeNames:   AlternativeNames(cert),
+	}
+}
+
+// ValidationResult verifies the certificate and returns the verified chain
+func ValidationResult(leaf *x509.Certificate, opts x509.VerifyOptions) (webcertificate.ValidationResult, []*x509.Certificate) {
+	var out webcertificate.ValidationResult
+	var chain []*x509.Certificate
+
+	if chains, err := leaf.Verify(opts); err != nil {
+		if errors.As(err, &x509.UnknownAuthorityError{}) && leaf.Subject.ToRDNSequence().String() == leaf.Issuer.ToRDNSequence().String() {
+			out = webcertificate.ValidationResult{
+				Value:   "valid-but-self-signed",
+				Message: "certificate verified successfully, but determined to be self signed",
+			}
+		} else {
+			out = webcertificate.ValidationResult{Value: "invalid", Message: fmt.Sprintf("failed to verify certificate: %s", err.Error())}
+		}
+	} else {
+		out = webcertificate.ValidationResult{Value: "valid", Message: "certificate verified successfully"}
+		chain = chains[0]
+	}
+
+	return out, chain
+}
+
+// Inspect builds the output of the leaf of the bundle, the rest of the bundle
+// is added to the intermediates of the verification
+func Inspect(bundle *Bundle, opts x509.VerifyOptions) (webcertificate.Output, []*x509.Certificate, error) {
+	var out webcertificate.Output
+	cert, err := bundle.Leaf()
+	if err != nil {
+		return out, nil, err
+	}
+	if opts.Intermediates == nil {
+		opts.Intermediates = x509.NewCertPool()
+	}
+	for _, intermediate := range bundle.Certificates[1:] {
+		opts.Intermediates.AddCert(intermediate)
+	}
+	out.X509 = NewCert(cert)
+	var chain []*x509.Certificate
+	out.Result, chain = ValidationResult(cert, opts)
+	out.Sha1Fingerprint = fmt.Sprintf("%x", sha1.Sum(cert.Raw))
+	out.Sha256Fingerprint = fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
+	return out, chain, nil
+}
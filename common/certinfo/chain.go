@@ -0,0 +1,179 @@
+package certinfo
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"golang.org/x/crypto/ocsp"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const MinRsaKeySize = 2048
+
+type OcspResult struct {
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+	RevokedAt int64  `json:"revoked_at,omitempty"`
+}
+
+type ChainInfo struct {
+	ChainLength   int         `json:"chain_length"`
+	WeakSignature bool        `json:"weak_signature"`
+	WeakKey       bool        `json:"weak_key"`
+	KeySize       int         `json:"key_size"`
+	Ocsp          *OcspResult `json:"ocsp,omitempty"`
+}
+
+// LoadTrustStore returns the system roots or the certificates of the given
+// PEM file or directory
+func LoadTrustStore(path string) (*x509.CertPool, error) {
+	if len(path) == 0 {
+		return x509.SystemCertPool()
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if stat.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		if stat, err := os.Stat(file); err != nil || stat.IsDir() {
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		pool.AppendCertsFromPEM(content)
+	}
+	return pool, nil
+}
+
+func KeySize(cert *x509.Certificate) int {
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return pub.N.BitLen()
+	case *ecdsa.PublicKey:
+		return pub.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	}
+	return 0
+}
+
+func IsWeakSignature(algo x509.SignatureAlgorithm) bool {
+	switch algo {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		return true
+	}
+	return false
+}
+
+func IsSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// GetChainInfo reports the weakest link of the chain, the root self signature
+// does not count
+func GetChainInfo(leaf *x509.Certificate, chain []*x509.Certificate) ChainInfo {
+	if len(chain) == 0 {
+		chain = []*x509.Certificate{leaf}
+	}
+	info := ChainInfo{ChainLength: len(chain), KeySize: KeySize(leaf)}
+	for _, cert := range chain {
+		if IsWeakSignature(cert.SignatureAlgorithm) && !IsSelfSigned(cert) {
+			info.WeakSignature = true
+		}
+		if rsaKey, ok := cert.PublicKey.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < MinRsaKeySize {
+			info.WeakKey = true
+		}
+	}
+	return info
+}
+
+// CheckOcsp asks the responder of the certificate, or the given url, for the
+// revocation status
+func CheckOcsp(leaf, issuer *x509.Certificate, url string) *OcspResult {
+	if issuer == nil {
+		return &OcspResult{Status: "error", Message: "issuer certificate not found"}
+	}
+	if len(url) == 0 {
+		if len(leaf.OCSPServer) == 0 {
+			return &OcspResult{Status: "unknown", Message: "no ocsp responder"}
+		}
+		url = leaf.OCSPServer[0]
+	}
+	request, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return &OcspResult{Status: "error", Message: err.Error()}
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	response, err := client.Post(url, "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return &OcspResult{Status: "error", Message: err.Error()}
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return &OcspResult{Status: "error", Message: fmt.Sprintf("unexpected response status code: %d", response.StatusCode)}
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return &OcspResult{Status: "error", Message: err.Error()}
+	}
+	return ocspResult(body, leaf, issuer)
+}
+
+// CheckStapledOcsp checks the response stapled to a TLS handshake, a server
+// without stapling is reported as unknown
+func CheckStapledOcsp(response []byte, leaf, issuer *x509.Certificate) *OcspResult {
+	if len(response) == 0 {
+		return &OcspResult{Status: "unknown", Message: "no stapled ocsp response"}
+	}
+	if issuer == nil {
+		return &OcspResult{Status: "error", Message: "issuer certificate not found"}
+	}
+	return ocspResult(response, leaf, issuer)
+}
+
+func ocspResult(body []byte, leaf, issuer *x509.Certificate) *OcspResult {
+	resp, err := ocsp.ParseResponseForCert(body, leaf, issuer)
+	if err != nil {
+		return &OcspResult{Status: "error", Message: err.Error()}
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		return &OcspResult{Status: "good"}
+	case ocsp.Revoked:
+		return &OcspResult{Status: "revoked", RevokedAt: resp.RevokedAt.Unix(), Message: fmt.Sprintf("revocation reason %d", resp.RevocationReason)}
+	}
+	return &OcspResult{Status: "unknown"}
+}
+
+// FindIssuer returns the issuer of the leaf from the verified chain or from
+// the certificates of the bundle
+func FindIssuer(leaf *x509.Certificate, chain []*x509.Certificate, bundle []*x509.Certificate) *x509.Certificate {
+	if len(chain) > 1 {
+		return chain[1]
+	}
+	for _, cert := range bundle {
+		if leaf.CheckSignatureFrom(cert) == nil {
+			return cert
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package certinfo
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// newTestCert returns a certificate signed by the parent, a nil parent
+// makes a self signed CA
+func newTestCert(t *testing.T, name string, serial int64, parent *x509.Certificate, parentKey crypto.Signer, ocspServer string) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+		parent, parentKey = template, key
+	} else {
+		template.DNSNames = []string{name}
+		template.OCSPServer = []string{ocspServer}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// newOcspResponse returns the response of the CA for a certificate, the
+// revoked ones have a revocation time
+func newOcspResponse(t *testing.T, ca *x509.Certificate, caKey crypto.Signer, serial *big.Int, revokedAt time.Time) []byte {
+	t.Helper()
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: serial,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+	}
+	if !revokedAt.IsZero() {
+		template.Status = ocsp.Revoked
+		template.RevokedAt = revokedAt
+		template.RevocationReason = ocsp.KeyCompromise
+	}
+	response, err := ocsp.CreateResponse(ca, ca, template, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestCheckOcsp(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var ca *x509.Certificate
+	var caKey crypto.Signer
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		request, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// the serial 2 is revoked
+		var revoked time.Time
+		if request.SerialNumber.Int64() == 2 {
+			revoked = revokedAt
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(newOcspResponse(t, ca, caKey, request.SerialNumber, revoked))
+	}))
+	defer responder.Close()
+	ca, caKey = newTestCert(t, "Test CA", 1, nil, nil, "")
+	good, _ := newTestCert(t, "good.example", 3, ca, caKey, responder.URL)
+	revoked, _ := newTestCert(t, "revoked.example", 2, ca, caKey, responder.URL)
+
+	// nothing listens on the address of a closed server
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name   string
+		leaf   *x509.Certificate
+		url    string
+		status string
+	}{
+		{"good", good, "", "good"},
+		{"revoked", revoked, "", "revoked"},
+		{"unreachable", good, closed.URL, "error"},
+	}
+	for _, test := range tests {
+		r := CheckOcsp(test.leaf, ca, test.url)
+		if r.Status != test.status {
+			t.Errorf("%s: got status %s: %s, want %s", test.name, r.Status, r.Message, test.status)
+		}
+	}
+	if r := CheckOcsp(revoked, ca, ""); r.RevokedAt != revokedAt.Unix() {
+		t.Errorf("got revoked at %d, want %d", r.RevokedAt, revokedAt.Unix())
+	}
+	if r := CheckOcsp(good, nil, ""); r.Status != "error" {
+		t.Errorf("got status %s without issuer", r.Status)
+	}
+}
+
+func TestCheckStapledOcsp(t *testing.T) {
+	ca, caKey := newTestCert(t, "Test CA", 1, nil, nil, "")
+	leaf, _ := newTestCert(t, "good.example", 3, ca, caKey, "http://127.0.0.1/")
+
+	if r := CheckStapledOcsp(newOcspResponse(t, ca, caKey, leaf.SerialNumber, time.Time{}), leaf, ca); r.Status != "good" {
+		t.Errorf("got status %s: %s", r.Status, r.Message)
+	}
+	if r := CheckStapledOcsp(nil, leaf, ca); r.Status != "unknown" {
+		t.Errorf("got status %s without stapling", r.Status)
+	}
+	// a response of another certificate
+	if r := CheckStapledOcsp(newOcspResponse(t, ca, caKey, big.NewInt(4), time.Time{}), leaf, ca); r.Status != "error" {
+		t.Errorf("got status %s for another serial", r.Status)
+	}
+}
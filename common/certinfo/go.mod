@@ -0,0 +1,12 @@
+module github.com/Elbandi/zabbix-checker/common/certinfo
+
+go 1.23.5
+
+require (
+	golang.org/x/crypto v0.32.0
+	golang.zabbix.com/agent2 v0.0.0-20250225074525-c34078a4563f
+)
+
+require golang.zabbix.com/sdk v1.2.2-0.20250214072554-abd5e97e6797 // indirect
+
+replace golang.zabbix.com/agent2 v0.0.0-20250225074525-c34078a4563f => github.com/zabbix/zabbix/src/go v0.0.0-20250225074525-c34078a4563f
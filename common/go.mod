@@ -0,0 +1,3 @@
+module github.com/Elbandi/zabbix-checker/common
+
+go 1.14
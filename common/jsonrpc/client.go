@@ -0,0 +1,139 @@
+package jsonrpc
+
+// Client of the bitcoind style JSON-RPC servers in HTTP POST mode, the
+// daemons of the bitcoin clones do not provide TLS by default.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTimeout = errors.New("rpc deadline exceeded")
+	ErrAuth    = errors.New("authentication failed")
+)
+
+// Error is an error answered by the server
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type request struct {
+	JsonRpc string        `json:"jsonrpc"`
+	Id      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// Client sends the requests to one server, the timeout is set on the http
+// client, so a hung request is cancelled with its connection
+type Client struct {
+	url      string
+	username string
+	password string
+	http     *http.Client
+	id       int
+}
+
+func NewClient(host string, port int, username, password string, timeout time.Duration) *Client {
+	return &Client{
+		url:      "http://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/",
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: timeout},
+	}
+}
+
+// ReadCookie returns the user and the password of an auth cookie file
+func ReadCookie(fileName string) (string, string, error) {
+	cookie, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.SplitN(strings.TrimSpace(string(cookie)), ":", 2)
+	if len(parts) != 2 {
+		return "", "", errors.New("invalid cookie file format")
+	}
+	return parts[0], parts[1], nil
+}
+
+// Close closes the idle connections of the client
+func (c *Client) Close() {
+	c.http.CloseIdleConnections()
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Call sends one request, the result is decoded into result if it is not nil
+func (c *Client) Call(method string, result interface{}, params ...interface{}) error {
+	c.id++
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(&request{JsonRpc: "1.0", Id: c.id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(c.username, c.password)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if isTimeout(err) {
+			return fmt.Errorf("%s: %w", method, ErrTimeout)
+		}
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%s: %w", method, ErrAuth)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		if isTimeout(err) {
+			return fmt.Errorf("%s: %w", method, ErrTimeout)
+		}
+		return err
+	}
+	// the daemon answers rpc errors with 404/500 status codes and a json body
+	var res response
+	if err := json.Unmarshal(data, &res); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: unexpected response status code: %d", method, resp.StatusCode)
+		}
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if res.Error != nil {
+		return fmt.Errorf("%s: %w", method, res.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(res.Result, result)
+}
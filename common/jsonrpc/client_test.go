@@ -0,0 +1,98 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client of a mock daemon which knows getblockcount
+// and sleep, the others are answered with a method not found error
+func newTestClient(t *testing.T, timeout time.Duration) *Client {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.Method {
+		case "getblockcount":
+			fmt.Fprintf(w, `{"result":%d,"error":null,"id":%d}`, 800000+len(req.Params), req.Id)
+		case "sleep":
+			time.Sleep(time.Second)
+			fmt.Fprintf(w, `{"result":null,"error":null,"id":%d}`, req.Id)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"result":null,"error":{"code":-32601,"message":"Method not found"},"id":%d}`, req.Id)
+		}
+	}))
+	t.Cleanup(s.Close)
+	host, port, err := net.SplitHostPort(s.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	return NewClient(host, p, "user", "secret", timeout)
+}
+
+func TestCall(t *testing.T) {
+	c := newTestClient(t, 5*time.Second)
+	defer c.Close()
+	var count int64
+	if err := c.Call("getblockcount", &count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 800000 {
+		t.Errorf("got %d", count)
+	}
+	if err := c.Call("getblockcount", &count, 1, "a"); err != nil || count != 800002 {
+		t.Errorf("got %d, %v with params", count, err)
+	}
+
+	err := c.Call("getfoo", nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("got %v", err)
+	}
+
+	c.password = "wrong"
+	if err := c.Call("getblockcount", &count); !errors.Is(err, ErrAuth) {
+		t.Errorf("got %v with a wrong password", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	c := newTestClient(t, 100*time.Millisecond)
+	if err := c.Call("sleep", nil); !errors.Is(err, ErrTimeout) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestReadCookie(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonrpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, ".cookie")
+	if err := ioutil.WriteFile(fileName, []byte("__cookie__:abc:def\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	user, password, err := ReadCookie(fileName)
+	if err != nil || user != "__cookie__" || password != "abc:def" {
+		t.Errorf("got %q %q %v", user, password, err)
+	}
+}
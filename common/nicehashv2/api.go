@@ -0,0 +1,311 @@
+package nicehashv2
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const pageSize = 100
+
+// Float is a decimal which the api sends either as a number or as a string
+type Float float64
+
+func (f *Float) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*f = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return err
+	}
+	*f = Float(v)
+	return nil
+}
+
+// Code is an enum of the api, like the type or the status of an order
+type Code struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+type Pool struct {
+	Id              string `json:"id"`
+	Name            string `json:"name"`
+	Algorithm       string `json:"algorithm"`
+	StratumHostname string `json:"stratumHostname"`
+	StratumPort     uint   `json:"stratumPort"`
+	Username        string `json:"username"`
+}
+
+// Order is an own hashpower order, the amounts are in BTC, the speeds in
+// DisplayMarketFactor units
+type Order struct {
+	Id        string `json:"id"`
+	Market    string `json:"market"`
+	Algorithm struct {
+		Algorithm string `json:"algorithm"`
+		Title     string `json:"title"`
+	} `json:"algorithm"`
+	Type                 Code   `json:"type"`
+	Status               Code   `json:"status"`
+	Alive                bool   `json:"alive"`
+	Pool                 Pool   `json:"pool"`
+	Price                Float  `json:"price"`
+	Limit                Float  `json:"limit"`
+	Amount               Float  `json:"amount"`
+	AvailableAmount      Float  `json:"availableAmount"`
+	PayedAmount          Float  `json:"payedAmount"`
+	AcceptedCurrentSpeed Float  `json:"acceptedCurrentSpeed"`
+	RigsCount            int    `json:"rigsCount"`
+	DisplayMarketFactor  string `json:"displayMarketFactor"`
+	MarketFactor         Float  `json:"marketFactor"`
+}
+
+// BookOrder is an order of the order book of a market
+type BookOrder struct {
+	Id            string `json:"id"`
+	Type          string `json:"type"`
+	Price         Float  `json:"price"`
+	Limit         Float  `json:"limit"`
+	RigsCount     int    `json:"rigsCount"`
+	AcceptedSpeed Float  `json:"acceptedSpeed"`
+	Alive         bool   `json:"alive"`
+}
+
+// Algorithm is a mining algorithm with its current paying price in BTC per
+// marketFactor H/s per day
+type Algorithm struct {
+	Algorithm string `json:"algorithm"`
+	Title     string `json:"title"`
+	Speed     Float  `json:"speed"`
+	Paying    Float  `json:"paying"`
+}
+
+type DeviceSpeed struct {
+	Algorithm     string `json:"algorithm"`
+	Title         string `json:"title"`
+	Speed         Float  `json:"speed"`
+	DisplaySuffix string `json:"displaySuffix"`
+}
+
+type Device struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Status struct {
+		EnumName string `json:"enumName"`
+	} `json:"status"`
+	Temperature Float         `json:"temperature"`
+	Load        Float         `json:"load"`
+	PowerUsage  Float         `json:"powerUsage"`
+	Speeds      []DeviceSpeed `json:"speeds"`
+}
+
+type RigStats struct {
+	Market    string `json:"market"`
+	Algorithm struct {
+		EnumName    string `json:"enumName"`
+		Description string `json:"description"`
+	} `json:"algorithm"`
+	UnpaidAmount       Float `json:"unpaidAmount"`
+	SpeedAccepted      Float `json:"speedAccepted"`
+	SpeedRejectedTotal Float `json:"speedRejectedTotal"`
+	Profitability      Float `json:"profitability"`
+}
+
+// Rig is a mining rig of the organization, the profitability is in BTC per
+// day
+type Rig struct {
+	RigId         string     `json:"rigId"`
+	Name          string     `json:"name"`
+	MinerStatus   string     `json:"minerStatus"`
+	UnpaidAmount  Float      `json:"unpaidAmount"`
+	Profitability Float      `json:"profitability"`
+	Devices       []Device   `json:"devices"`
+	Stats         []RigStats `json:"stats"`
+}
+
+type Rigs struct {
+	TotalRigs          int            `json:"totalRigs"`
+	MinerStatuses      map[string]int `json:"minerStatuses"`
+	TotalProfitability Float          `json:"totalProfitability"`
+	UnpaidAmount       Float          `json:"unpaidAmount"`
+	MiningRigs         []Rig          `json:"miningRigs"`
+}
+
+// Balance is the wallet balance of a currency
+type Balance struct {
+	Currency     string `json:"currency"`
+	TotalBalance Float  `json:"totalBalance"`
+	Available    Float  `json:"available"`
+	Pending      Float  `json:"pending"`
+	BtcRate      Float  `json:"btcRate"`
+}
+
+type Accounts struct {
+	Total      Balance   `json:"total"`
+	Currencies []Balance `json:"currencies"`
+}
+
+// GetAlgorithms returns the paying price of the algorithms
+func (c *Client) GetAlgorithms() ([]Algorithm, error) {
+	var response struct {
+		MiningAlgorithms []Algorithm `json:"miningAlgorithms"`
+	}
+	if err := c.get("/main/api/v2/public/simplemultialgo/info", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.MiningAlgorithms, nil
+}
+
+// GetOrderBook returns the orders of an algorithm by market
+func (c *Client) GetOrderBook(algorithm string) (map[string][]BookOrder, error) {
+	var response struct {
+		Stats map[string]struct {
+			Orders []BookOrder `json:"orders"`
+		} `json:"stats"`
+	}
+	query := url.Values{"algorithm": {algorithm}, "page": {"0"}, "size": {"1000"}}
+	if err := c.get("/main/api/v2/hashpower/orderBook", query, &response); err != nil {
+		return nil, err
+	}
+	book := make(map[string][]BookOrder, len(response.Stats))
+	for market, stats := range response.Stats {
+		book[market] = stats.Orders
+	}
+	return book, nil
+}
+
+// GetMyOrders returns the active hashpower orders, algorithm and market are
+// optional filters
+func (c *Client) GetMyOrders(algorithm, market string) ([]Order, error) {
+	var orders []Order
+	for page := 0; ; page++ {
+		var response struct {
+			List []Order `json:"list"`
+		}
+		now, err := c.now()
+		if err != nil {
+			return nil, err
+		}
+		query := url.Values{
+			"active":    {"true"},
+			"op":        {"LE"},
+			"timestamp": {strconv.FormatInt(now, 10)},
+			"page":      {strconv.Itoa(page)},
+			"size":      {strconv.Itoa(pageSize)},
+		}
+		if len(algorithm) > 0 {
+			query.Set("algorithm", algorithm)
+		}
+		if len(market) > 0 {
+			query.Set("market", market)
+		}
+		if err := c.getSigned("/main/api/v2/hashpower/myOrders", query, &response); err != nil {
+			return nil, err
+		}
+		orders = append(orders, response.List...)
+		if len(response.List) < pageSize {
+			return orders, nil
+		}
+	}
+}
+
+// GetRigs returns the mining rigs with their devices and stats
+func (c *Client) GetRigs() (*Rigs, error) {
+	var rigs Rigs
+	if err := c.getSigned("/main/api/v2/mining/rigs2", nil, &rigs); err != nil {
+		return nil, err
+	}
+	return &rigs, nil
+}
+
+// GetAccounts returns the wallet balances
+func (c *Client) GetAccounts() (*Accounts, error) {
+	var accounts Accounts
+	if err := c.getSigned("/main/api/v2/accounting/accounts2", nil, &accounts); err != nil {
+		return nil, err
+	}
+	return &accounts, nil
+}
+
+// SpeedPercent returns the accepted speed of the order in percent of the
+// limit, zero for unlimited orders
+func (o *Order) SpeedPercent() float64 {
+	if o.Limit <= 0 {
+		return 0.00
+	}
+	return 100.0 * float64(o.AcceptedCurrentSpeed) / float64(o.Limit)
+}
+
+// LowPrice returns the lowest price of the alive orders with rigs, false if
+// there is no such order
+func LowPrice(orders []BookOrder) (float64, bool) {
+	found := false
+	var minprice float64
+	for _, order := range orders {
+		if order.Alive && order.RigsCount > 0 && (!found || float64(order.Price) < minprice) {
+			minprice = float64(order.Price)
+			found = true
+		}
+	}
+	return minprice, found
+}
+
+// MiningAlgorithm is the unit setting of an algorithm, the speeds of the
+// market are in marketFactor H/s
+type MiningAlgorithm struct {
+	Algorithm           string `json:"algorithm"`
+	Title               string `json:"title"`
+	Enabled             bool   `json:"enabled"`
+	MarketFactor        Float  `json:"marketFactor"`
+	DisplayMarketFactor string `json:"displayMarketFactor"`
+}
+
+// GetMiningAlgorithms returns the unit settings of the algorithms
+func (c *Client) GetMiningAlgorithms() ([]MiningAlgorithm, error) {
+	var response struct {
+		MiningAlgorithms []MiningAlgorithm `json:"miningAlgorithms"`
+	}
+	if err := c.get("/main/api/v2/mining/algorithms", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.MiningAlgorithms, nil
+}
+
+// ExchangeRate is the price of a currency in another one
+type ExchangeRate struct {
+	FromCurrency string `json:"fromCurrency"`
+	ToCurrency   string `json:"toCurrency"`
+	ExchangeRate Float  `json:"exchangeRate"`
+}
+
+// GetExchangeRates returns the fiat exchange rates of the currencies
+func (c *Client) GetExchangeRates() ([]ExchangeRate, error) {
+	var response struct {
+		List []ExchangeRate `json:"list"`
+	}
+	if err := c.get("/main/api/v2/exchangeRate/list", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.List, nil
+}
+
+// GetExchangeRate returns the price of one from currency in the to currency,
+// like BTC in USD
+func (c *Client) GetExchangeRate(from, to string) (float64, error) {
+	rates, err := c.GetExchangeRates()
+	if err != nil {
+		return 0, err
+	}
+	for _, rate := range rates {
+		if strings.EqualFold(rate.FromCurrency, from) && strings.EqualFold(rate.ToCurrency, to) && rate.ExchangeRate > 0 {
+			return float64(rate.ExchangeRate), nil
+		}
+	}
+	return 0, fmt.Errorf("no %s/%s exchange rate", from, to)
+}
@@ -0,0 +1,231 @@
+package nicehashv2
+
+// Client of the NiceHash API v2, the private endpoints are authenticated with
+// an HMAC-SHA256 signature of the request made with the api key and secret of
+// an organization.
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultBaseURL = "https://api2.nicehash.com"
+
+// the clock offset is queried again after this time, or if a signed request
+// is rejected
+const timeSyncInterval = time.Hour
+
+var ErrNoCredentials = errors.New("no organization id, api key or api secret specified")
+
+// Client is safe for concurrent use
+type Client struct {
+	baseURL   string
+	orgId     string
+	apiKey    string
+	apiSecret string
+	userAgent string
+	http      *http.Client
+	debug     bool
+
+	// difference of the server and the local clock in milliseconds, the
+	// signed requests are rejected if the time is off by minutes
+	mu         sync.Mutex
+	timeOffset int64
+	timeSynced time.Time
+}
+
+// Error is the error response of the api
+type Error struct {
+	StatusCode int
+	Errors     []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (e *Error) Error() string {
+	var messages []string
+	for _, err := range e.Errors {
+		messages = append(messages, fmt.Sprintf("%s (%d)", err.Message, err.Code))
+	}
+	if len(messages) == 0 {
+		return fmt.Sprintf("nicehash: http status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("nicehash: http status %d: %s", e.StatusCode, strings.Join(messages, ", "))
+}
+
+// NewClient returns a new client, the public endpoints work without the
+// organization id, api key and secret
+func NewClient(httpClient *http.Client, baseURL, orgId, apiKey, apiSecret, userAgent string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if len(baseURL) == 0 {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		orgId:     orgId,
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		userAgent: userAgent,
+		http:      httpClient,
+	}
+}
+
+// SetDebug enables the request/response dump
+func (c *Client) SetDebug(debug bool) {
+	c.debug = debug
+}
+
+// Sign returns the X-Auth signature of a request, the body is signed only if
+// the request has one
+func Sign(apiKey, apiSecret, xtime, nonce, orgId, method, path, query string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(apiSecret))
+	for i, part := range []string{apiKey, xtime, nonce, "", orgId, "", method, path, query} {
+		if i > 0 {
+			mac.Write([]byte{0})
+		}
+		mac.Write([]byte(part))
+	}
+	if len(body) > 0 {
+		mac.Write([]byte{0})
+		mac.Write(body)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newNonce returns a random uuid
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// ServerTime returns the time of the server in milliseconds
+func (c *Client) ServerTime() (int64, error) {
+	var response struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := c.do(http.MethodGet, "/api/v2/time", nil, false, &response); err != nil {
+		return 0, err
+	}
+	return response.ServerTime, nil
+}
+
+// now returns the server time in milliseconds, the clock offset is queried
+// again every timeSyncInterval
+func (c *Client) now() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timeSynced.IsZero() || time.Since(c.timeSynced) > timeSyncInterval {
+		local := time.Now().UnixNano() / int64(time.Millisecond)
+		server, err := c.ServerTime()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get the server time: %w", err)
+		}
+		c.timeOffset = server - local
+		c.timeSynced = time.Now()
+	}
+	return time.Now().UnixNano()/int64(time.Millisecond) + c.timeOffset, nil
+}
+
+// resetTime makes the next signed request query the clock offset
+func (c *Client) resetTime() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timeSynced = time.Time{}
+}
+
+func (c *Client) get(path string, query url.Values, out interface{}) error {
+	return c.do(http.MethodGet, path, query, false, out)
+}
+
+func (c *Client) getSigned(path string, query url.Values, out interface{}) error {
+	return c.do(http.MethodGet, path, query, true, out)
+}
+
+// do sends the request, a rejected signed request is sent again once with a
+// new clock offset, as the local clock may have jumped since the last query
+func (c *Client) do(method, path string, query url.Values, signed bool, out interface{}) error {
+	err := c.request(method, path, query, signed, out)
+	var e *Error
+	if signed && errors.As(err, &e) && e.StatusCode == http.StatusUnauthorized {
+		c.resetTime()
+		err = c.request(method, path, query, signed, out)
+	}
+	return err
+}
+
+func (c *Client) request(method, path string, query url.Values, signed bool, out interface{}) error {
+	rawQuery := query.Encode()
+	u := c.baseURL + path
+	if len(rawQuery) > 0 {
+		u += "?" + rawQuery
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return err
+	}
+	if len(c.userAgent) > 0 {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	req.Header.Set("Accept", "application/json")
+	if signed {
+		if len(c.orgId) == 0 || len(c.apiKey) == 0 || len(c.apiSecret) == 0 {
+			return ErrNoCredentials
+		}
+		now, err := c.now()
+		if err != nil {
+			return err
+		}
+		nonce, err := newNonce()
+		if err != nil {
+			return err
+		}
+		xtime := strconv.FormatInt(now, 10)
+		req.Header.Set("X-Time", xtime)
+		req.Header.Set("X-Nonce", nonce)
+		req.Header.Set("X-Organization-Id", c.orgId)
+		req.Header.Set("X-Request-Id", nonce)
+		req.Header.Set("X-Auth", c.apiKey+":"+Sign(c.apiKey, c.apiSecret, xtime, nonce, c.orgId, method, path, rawQuery, nil))
+	}
+	if c.debug {
+		log.Printf("%s %s", method, u)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if c.debug {
+		log.Printf("%s %s", resp.Status, data)
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(data, e)
+		return e
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,226 @@
+package nicehashv2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testOrgId  = "org-1"
+	testKey    = "key-1"
+	testSecret = "secret-1"
+	// the signed requests are rejected if X-Time is off by more
+	testTimeWindow = 5 * time.Minute
+)
+
+// testServer is a mock of the api which checks the signature and the time of
+// the signed requests like the real one
+type testServer struct {
+	*httptest.Server
+	mu         sync.Mutex
+	offset     time.Duration
+	timeCalls  int
+	orderPages []string
+	orders     int
+}
+
+func newTestServer(t *testing.T, offset time.Duration) *testServer {
+	s := &testServer{offset: offset}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		serverTime := time.Now().Add(s.offset).UnixNano() / int64(time.Millisecond)
+		if r.URL.Path == "/api/v2/time" {
+			s.timeCalls++
+			fmt.Fprintf(w, `{"serverTime":%d}`, serverTime)
+			return
+		}
+		if r.URL.Path == "/main/api/v2/exchangeRate/list" {
+			fmt.Fprint(w, `{"list":[{"fromCurrency":"BTC","toCurrency":"EUR","exchangeRate":"55000.5"},`+
+				`{"fromCurrency":"BTC","toCurrency":"USD","exchangeRate":"60123.45"}]}`)
+			return
+		}
+		if err := s.checkAuth(r, serverTime); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"errors":[{"code":2000,"message":%q}]}`, err.Error())
+			return
+		}
+		switch r.URL.Path {
+		case "/main/api/v2/hashpower/myOrders":
+			s.writeOrders(w, r)
+		case "/main/api/v2/mining/rigs2":
+			fmt.Fprint(w, `{"totalRigs":1,"minerStatuses":{"MINING":1},"totalProfitability":0.0001,"unpaidAmount":"0.00002",`+
+				`"miningRigs":[{"rigId":"rig-1","name":"worker1","minerStatus":"MINING","unpaidAmount":"0.00002","profitability":0.0001,`+
+				`"devices":[{"id":"dev-1","name":"GPU0","status":{"enumName":"MINING"},"temperature":65,"load":"99.5","powerUsage":120,`+
+				`"speeds":[{"algorithm":"DAGGERHASHIMOTO","title":"DaggerHashimoto","speed":"30.5","displaySuffix":"MH"}]}],`+
+				`"stats":[{"market":"EU","algorithm":{"enumName":"DAGGERHASHIMOTO","description":"DaggerHashimoto"},`+
+				`"unpaidAmount":"0.00002","speedAccepted":30.5,"speedRejectedTotal":0.1,"profitability":0.0001}]}]}`)
+		case "/main/api/v2/accounting/accounts2":
+			fmt.Fprint(w, `{"total":{"currency":"TBTC","totalBalance":"0.5","available":"0.4","pending":"0.1","btcRate":1},`+
+				`"currencies":[{"currency":"BTC","totalBalance":"0.5","available":"0.4","pending":"0.1","btcRate":1},`+
+				`{"currency":"ETH","totalBalance":"2","available":"2","pending":"0","btcRate":"0.05"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// checkAuth recomputes the signature from the headers and the request
+func (s *testServer) checkAuth(r *http.Request, serverTime int64) error {
+	xtime, nonce, orgId := r.Header.Get("X-Time"), r.Header.Get("X-Nonce"), r.Header.Get("X-Organization-Id")
+	if orgId != testOrgId || len(nonce) == 0 {
+		return fmt.Errorf("missing headers")
+	}
+	expected := testKey + ":" + Sign(testKey, testSecret, xtime, nonce, orgId, r.Method, r.URL.Path, r.URL.RawQuery, nil)
+	if r.Header.Get("X-Auth") != expected {
+		return fmt.Errorf("invalid signature")
+	}
+	t, err := strconv.ParseInt(xtime, 10, 64)
+	if err != nil {
+		return err
+	}
+	if d := time.Duration(t-serverTime) * time.Millisecond; d > testTimeWindow || d < -testTimeWindow {
+		return fmt.Errorf("invalid time")
+	}
+	return nil
+}
+
+func (s *testServer) writeOrders(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	s.orderPages = append(s.orderPages, r.URL.Query().Get("page"))
+	var list []string
+	for i := page * size; i < s.orders && i < (page+1)*size; i++ {
+		list = append(list, fmt.Sprintf(`{"id":"order-%d","market":"EU","algorithm":{"algorithm":"SCRYPT"},"type":{"code":"STANDARD"},"alive":true,"price":"0.%04d","limit":"2","acceptedCurrentSpeed":"0.5"}`, i, i))
+	}
+	fmt.Fprintf(w, `{"list":[%s]}`, strings.Join(list, ","))
+}
+
+func newTestClient(s *testServer) *Client {
+	return NewClient(s.Client(), s.URL, testOrgId, testKey, testSecret, "test")
+}
+
+func TestGetMyOrdersPaging(t *testing.T) {
+	s := newTestServer(t, 2*time.Hour)
+	s.orders = 2*pageSize + 1
+	orders, err := newTestClient(s).GetMyOrders("SCRYPT", "EU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != s.orders {
+		t.Fatalf("got %d orders, want %d", len(orders), s.orders)
+	}
+	if strings.Join(s.orderPages, ",") != "0,1,2" {
+		t.Errorf("got pages %v", s.orderPages)
+	}
+	if s.timeCalls != 1 {
+		t.Errorf("got %d time queries, want 1", s.timeCalls)
+	}
+	last := orders[len(orders)-1]
+	if last.Id != fmt.Sprintf("order-%d", s.orders-1) || float64(last.Price) != 0.0200 || last.SpeedPercent() != 25 {
+		t.Errorf("got %+v", last)
+	}
+}
+
+func TestClockOffset(t *testing.T) {
+	s := newTestServer(t, -3*time.Hour)
+	c := newTestClient(s)
+	if _, err := c.GetAccounts(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Duration(c.timeOffset)*time.Millisecond + 3*time.Hour; d > time.Second || d < -time.Second {
+		t.Errorf("got offset %d ms", c.timeOffset)
+	}
+
+	// the server clock jumped, the rejected request is sent again
+	s.mu.Lock()
+	s.offset = time.Hour
+	s.mu.Unlock()
+	if _, err := c.GetAccounts(); err != nil {
+		t.Fatal(err)
+	}
+	if s.timeCalls != 2 {
+		t.Errorf("got %d time queries, want 2", s.timeCalls)
+	}
+
+	// the offset is queried again after the interval
+	c.timeSynced = c.timeSynced.Add(-timeSyncInterval - time.Second)
+	if _, err := c.GetAccounts(); err != nil {
+		t.Fatal(err)
+	}
+	if s.timeCalls != 3 {
+		t.Errorf("got %d time queries, want 3", s.timeCalls)
+	}
+}
+
+func TestInvalidSignature(t *testing.T) {
+	s := newTestServer(t, 0)
+	c := NewClient(s.Client(), s.URL, testOrgId, testKey, "wrong", "test")
+	_, err := c.GetAccounts()
+	e, ok := err.(*Error)
+	if !ok || e.StatusCode != http.StatusUnauthorized || len(e.Errors) != 1 || e.Errors[0].Code != 2000 {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestGetRigs(t *testing.T) {
+	s := newTestServer(t, 0)
+	rigs, err := newTestClient(s).GetRigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rigs.TotalRigs != 1 || rigs.MinerStatuses["MINING"] != 1 || float64(rigs.UnpaidAmount) != 0.00002 || len(rigs.MiningRigs) != 1 {
+		t.Fatalf("got %+v", rigs)
+	}
+	rig := rigs.MiningRigs[0]
+	if rig.RigId != "rig-1" || rig.MinerStatus != "MINING" || len(rig.Devices) != 1 || len(rig.Stats) != 1 {
+		t.Fatalf("got %+v", rig)
+	}
+	device := rig.Devices[0]
+	if device.Status.EnumName != "MINING" || float64(device.Load) != 99.5 || len(device.Speeds) != 1 || float64(device.Speeds[0].Speed) != 30.5 {
+		t.Errorf("got %+v", device)
+	}
+	if stats := rig.Stats[0]; stats.Algorithm.EnumName != "DAGGERHASHIMOTO" || float64(stats.SpeedAccepted) != 30.5 {
+		t.Errorf("got %+v", stats)
+	}
+}
+
+func TestGetAccounts(t *testing.T) {
+	s := newTestServer(t, 0)
+	accounts, err := newTestClient(s).GetAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if float64(accounts.Total.TotalBalance) != 0.5 || len(accounts.Currencies) != 2 {
+		t.Fatalf("got %+v", accounts)
+	}
+	if eth := accounts.Currencies[1]; eth.Currency != "ETH" || float64(eth.TotalBalance) != 2 || float64(eth.BtcRate) != 0.05 {
+		t.Errorf("got %+v", eth)
+	}
+}
+
+func TestGetExchangeRate(t *testing.T) {
+	s := newTestServer(t, 0)
+	c := NewClient(s.Client(), s.URL, "", "", "", "test")
+	rate, err := c.GetExchangeRate("BTC", "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 60123.45 {
+		t.Errorf("got %f", rate)
+	}
+	if _, err := c.GetExchangeRate("BTC", "HUF"); err == nil {
+		t.Error("got a rate of a missing pair")
+	}
+	if s.timeCalls != 0 {
+		t.Errorf("got %d time queries for a public call", s.timeCalls)
+	}
+}
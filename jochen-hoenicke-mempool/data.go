@@ -91,3 +91,44 @@ func ProcessDatat(data Data) ([]zabbix, error) {
 	}
 	return res, nil
 }
+
+// rangeIndex returns the index of the fee rate bucket (sat/vbyte) in ranges
+func rangeIndex(feeRate float64) int {
+	idx := 0
+	for i, r := range ranges {
+		if feeRate < float64(r) {
+			break
+		}
+		idx = i
+	}
+	return idx
+}
+
+// BuildData sorts the mempool entries into the same fee rate buckets as jochen-hoenicke.de
+func BuildData(date time.Time, entries map[string]MempoolEntry) Data {
+	d := Data{
+		Date:   date,
+		Count:  make([]int, len(ranges)),
+		Weight: make([]int, len(ranges)),
+		Fees:   make([]int, len(ranges)),
+	}
+	for _, entry := range entries {
+		vsize := entry.VSize
+		if vsize == 0 {
+			vsize = entry.Size
+		}
+		if vsize == 0 {
+			continue
+		}
+		weight := entry.Weight
+		if weight == 0 {
+			weight = vsize * 4
+		}
+		fee := entry.FeeSatoshi()
+		idx := rangeIndex(float64(fee) / float64(vsize))
+		d.Count[idx]++
+		d.Weight[idx] += weight
+		d.Fees[idx] += int(fee)
+	}
+	return d
+}
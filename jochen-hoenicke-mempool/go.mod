@@ -3,6 +3,7 @@ module jochen-hoenicke-mempool
 go 1.18
 
 require (
+	github.com/Elbandi/zabbix-checker/common v0.0.0-00010101000000-000000000000
 	github.com/go-errors/errors v1.5.1
 	github.com/urfave/cli/v2 v2.27.5
 )
@@ -12,3 +13,5 @@ require (
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20240521201337-686a1a2994c1 // indirect
 )
+
+replace github.com/Elbandi/zabbix-checker/common => ../common
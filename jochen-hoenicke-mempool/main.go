@@ -15,10 +15,12 @@ func main() {
 	app := &cli.App{
 		Name:        "jochen-hoenicke-mempool",
 		Version:     "v1.0",
-		Description: "Get data from jochen-hoenicke.de",
+		Description: "Get mempool data from jochen-hoenicke.de or own node",
 		Commands: []*cli.Command{
 			&fetchCommand,
 			&trapperCommand,
+			&nodeCommand,
+			&estimateFeeCommand,
 		},
 	}
 
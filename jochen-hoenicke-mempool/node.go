@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"math"
+	"time"
+)
+
+type MempoolEntry struct {
+	Size   int     `json:"size"`
+	VSize  int     `json:"vsize"`
+	Weight int     `json:"weight"`
+	Fee    float64 `json:"fee"`
+	Fees   struct {
+		Base float64 `json:"base"`
+	} `json:"fees"`
+}
+
+// FeeSatoshi returns the transaction fee in satoshi, newer nodes report it only in fees.base
+func (e MempoolEntry) FeeSatoshi() int64 {
+	fee := e.Fees.Base
+	if fee == 0 {
+		fee = e.Fee
+	}
+	return int64(math.Round(fee * 1e8))
+}
+
+type smartFee struct {
+	FeeRate float64  `json:"feerate"`
+	Errors  []string `json:"errors"`
+	Blocks  int      `json:"blocks"`
+}
+
+type estimateZabbix struct {
+	Target  int     `json:"target"`
+	Blocks  int     `json:"blocks"`
+	FeeRate float64 `json:"feerate"`
+}
+
+var nodeCommand = cli.Command{
+	Name:  "node",
+	Usage: "mempool data from own node",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "key",
+			Usage: "Item key, output trapper data when set",
+		},
+	}, rpcFlags...),
+	Action: cmdNode,
+}
+
+var estimateFeeCommand = cli.Command{
+	Name:  "estimatefee",
+	Usage: "smart fee estimates from own node",
+	Flags: append([]cli.Flag{
+		&cli.IntSliceFlag{
+			Name:  "target",
+			Usage: "Confirmation target in blocks",
+			Value: cli.NewIntSlice(2, 6, 12, 24, 144),
+		},
+		&cli.GenericFlag{
+			Name:  "mode",
+			Usage: "Estimate mode",
+			Value: &EnumValue{
+				Enum:    []string{"conservative", "economical"},
+				Default: "conservative",
+			},
+		},
+	}, rpcFlags...),
+	Action: cmdEstimateFee,
+}
+
+func cmdNode(ctx *cli.Context) error {
+	client, err := NewRpcClient(ctx)
+	if err != nil {
+		return err
+	}
+	var entries map[string]MempoolEntry
+	err = client.Call("getrawmempool", &entries, true)
+	if err != nil {
+		return err
+	}
+	data := BuildData(time.Now(), entries)
+	res, err := ProcessDatat(data)
+	if err != nil {
+		return err
+	}
+	d, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	if ctx.IsSet("key") {
+		fmt.Printf("- %s %d %q\n", ctx.String("key"), data.Date.Unix(), string(d))
+	} else {
+		fmt.Println(string(d))
+	}
+	return nil
+}
+
+func cmdEstimateFee(ctx *cli.Context) error {
+	client, err := NewRpcClient(ctx)
+	if err != nil {
+		return err
+	}
+	res := make([]estimateZabbix, 0)
+	for _, target := range ctx.IntSlice("target") {
+		var fee smartFee
+		err = client.Call("estimatesmartfee", &fee, target, ctx.String("mode"))
+		if err != nil {
+			return err
+		}
+		if len(fee.Errors) > 0 && fee.FeeRate == 0 {
+			return fmt.Errorf("estimatesmartfee %d: %s", target, fee.Errors[0])
+		}
+		res = append(res, estimateZabbix{
+			Target: target,
+			Blocks: fee.Blocks,
+			// BTC/kvB to sat/vB, same unit as the txfee ranges
+			FeeRate: fee.FeeRate * 1e5,
+		})
+	}
+	d, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(d))
+	return nil
+}
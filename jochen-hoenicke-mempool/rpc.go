@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/Elbandi/zabbix-checker/common/jsonrpc"
+	"github.com/urfave/cli/v2"
+	"time"
+)
+
+var rpcFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "rpcconnect",
+		Usage: "Send commands to node running on host",
+		Value: "127.0.0.1",
+	},
+	&cli.IntFlag{
+		Name:  "rpcport",
+		Usage: "Connect to JSON-RPC port",
+		Value: 8332,
+	},
+	&cli.StringFlag{
+		Name:    "rpcuser",
+		Usage:   "Username for JSON-RPC connections",
+		EnvVars: []string{"RPC_USER"},
+	},
+	&cli.StringFlag{
+		Name:    "rpcpassword",
+		Usage:   "Password for JSON-RPC connections",
+		EnvVars: []string{"RPC_PASSWORD"},
+	},
+	&cli.StringFlag{
+		Name:  "rpccookiefile",
+		Usage: "Location of the auth cookie, used when no rpcuser is given",
+	},
+	&cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "JSON-RPC request timeout",
+		Value: 30 * time.Second,
+	},
+}
+
+// NewRpcClient returns a client of the node given by the rpc flags, the
+// auth cookie is used when no rpcuser is given
+func NewRpcClient(ctx *cli.Context) (*jsonrpc.Client, error) {
+	username, password := ctx.String("rpcuser"), ctx.String("rpcpassword")
+	if len(username) == 0 && ctx.IsSet("rpccookiefile") {
+		var err error
+		if username, password, err = jsonrpc.ReadCookie(ctx.String("rpccookiefile")); err != nil {
+			return nil, err
+		}
+	}
+	return jsonrpc.NewClient(ctx.String("rpcconnect"), ctx.Int("rpcport"), username, password, ctx.Duration("timeout")), nil
+}
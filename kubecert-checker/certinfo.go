@@ -1,129 +1,135 @@
 package main
 
 import (
-	"crypto/sha1"
-	"crypto/sha256"
 	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
 	"errors"
 	"fmt"
+	"github.com/Elbandi/zabbix-checker/common/certinfo"
 	"github.com/urfave/cli/v2"
 	webcertificate "golang.zabbix.com/agent2/plugins/web/certificate"
-	"os"
-	"path/filepath"
-	"strings"
 )
 
 const (
-	dateFormat = "Jan 02 15:04:05 2006 GMT"
+	stateOk    = "ok"
+	stateError = "error"
 )
 
 type KubernetesCert struct {
 	webcertificate.Output
-	Name string `json:"name"`
+	certinfo.ChainInfo
+	KeyInfo
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
 }
 
 var certInfoCommand = cli.Command{
-	Name:   "certinfo",
-	Usage:  "certinfo /var/lib/rancher/k3s",
+	Name:  "certinfo",
+	Usage: "certinfo [--glob PATTERN] FILE|DIR|LAYOUT:/var/lib/rancher/k3s...",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "glob",
+			Usage: "File name pattern of the certificates in the directories",
+			Value: "*",
+		},
+	},
 	Action: cmdCertInfo,
 }
 
-func getValidationResult(leaf *x509.Certificate, opts x509.VerifyOptions, subject, issuer string) webcertificate.ValidationResult {
-	var out webcertificate.ValidationResult
+// errorCert is the output entry of a certificate which could not be read
+func errorCert(name string, err error) KubernetesCert {
+	var o KubernetesCert
+	o.Name = name
+	o.State = stateError
+	o.Error = err.Error()
+	o.Result = webcertificate.ValidationResult{Value: stateError, Message: err.Error()}
+	return o
+}
 
-	if _, err := leaf.Verify(opts); err != nil {
-		if errors.As(err, &x509.UnknownAuthorityError{}) && subject == issuer {
-			out = webcertificate.ValidationResult{
-				Value:   "valid-but-self-signed",
-				Message: "certificate verified successfully, but determined to be self signed",
-			}
-		} else {
-			out = webcertificate.ValidationResult{Value: "invalid", Message: fmt.Sprintf("failed to verify certificate: %s", err.Error())}
+// collectSources returns the certificates of every path argument, a file
+// which could not be parsed is kept with its error and without a bundle.
+// The paths without a LAYOUT: prefix have the given layout, the glob is used
+// by the file layout.
+func collectSources(ctx *cli.Context, defaultLayout, glob string) ([]certSource, error) {
+	if ctx.NArg() < 1 {
+		return nil, errors.New("missing path")
+	}
+	sources := make([]certSource, 0)
+	for _, root := range ctx.Args().Slice() {
+		layout, path := splitRoot(root, defaultLayout)
+		s, err := findSources(path, layout, glob)
+		if err != nil {
+			return nil, err
+		}
+		for _, source := range s {
+			source.Bundle, source.Err = certinfo.Parse(source.Data, ctx.String("password"))
+			sources = append(sources, source)
 		}
-	} else {
-		out = webcertificate.ValidationResult{Value: "valid", Message: "certificate verified successfully"}
 	}
+	return sources, nil
+}
 
-	return out
+// findRoots collects the self signed CA certificates of the sources
+func findRoots(sources []certSource) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, source := range sources {
+		if source.Err != nil {
+			continue
+		}
+		for _, cert := range source.Bundle.Certificates {
+			if cert.IsCA && certinfo.IsSelfSigned(cert) {
+				pool.AddCert(cert)
+			}
+		}
+	}
+	return pool
 }
 
+// cmdCertInfo reads any certificate file or the matching files of a
+// directory, the layout roots need the LAYOUT: prefix or the --layout flag
 func cmdCertInfo(ctx *cli.Context) error {
-	if ctx.NArg() < 1 {
-		return errors.New("missing rancher path")
+	layout := layoutFile
+	if ctx.IsSet("layout") {
+		layout = ctx.String("layout")
 	}
-	rancherPath := ctx.Args().First()
-	ltsPath := filepath.Join(rancherPath, "server", "tls")
-	if stat, err := os.Stat(ltsPath); (err != nil) || !stat.IsDir() {
-		return errors.New("invalid rancher path")
-	}
-	output := make([]KubernetesCert, 0)
-	ltsPath, err := filepath.Abs(ltsPath)
+	sources, err := collectSources(ctx, layout, ctx.String("glob"))
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path for '%s': %w", ltsPath, err)
+		return err
 	}
-	err = filepath.Walk(ltsPath, func(path string, info os.FileInfo, err error) error {
+	roots := findRoots(sources)
+	if ctx.IsSet("ca-file") {
+		roots, err = certinfo.LoadTrustStore(ctx.String("ca-file"))
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to load trust store: %w", err)
 		}
-		if info.IsDir() {
-			return nil
-		}
-		if ltsPath == path {
-			return nil
-		}
-		if !strings.HasSuffix(path, ".crt") {
-			return nil
-		}
-		fullPath, err := filepath.Abs(path)
-		if err != nil {
-			return err
+	}
+	output := make([]KubernetesCert, 0)
+	for _, source := range sources {
+		if source.Err != nil {
+			output = append(output, errorCert(source.Name, source.Err))
+			continue
 		}
-		certPEM, err := os.ReadFile(fullPath)
+		cert, err := source.Bundle.Leaf()
 		if err != nil {
-			return fmt.Errorf("failed to read certificate '%s' file: %w", fullPath, err)
-		}
-		block, rest := pem.Decode(certPEM)
-		if block == nil {
-			return fmt.Errorf("failed to decode PEM block from '%s' file", fullPath)
+			output = append(output, errorCert(source.Name, err))
+			continue
 		}
-		cert, err := x509.ParseCertificate(block.Bytes)
+		var o KubernetesCert
+		o.Name = source.Name
+		o.State = stateOk
+		var chain []*x509.Certificate
+		o.Output, chain, err = certinfo.Inspect(source.Bundle, x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
 		if err != nil {
-			return fmt.Errorf("failed to parse certificate: %w", err)
-		}
-		rootPool := x509.NewCertPool()
-		if len(rest) > 0 {
-			ok := rootPool.AppendCertsFromPEM(rest)
-			if !ok {
-				return fmt.Errorf("couldn't add pems from fullchain")
-			}
+			output = append(output, errorCert(source.Name, err))
+			continue
 		}
-		var o KubernetesCert
-		o.Name = strings.TrimPrefix(fullPath, ltsPath+string(os.PathSeparator))
-		o.X509 = webcertificate.Cert{
-			Version:            cert.Version,
-			Serial:             fmt.Sprintf("%x", cert.SerialNumber.Bytes()),
-			SignatureAlgorithm: cert.SignatureAlgorithm.String(),
-			Issuer:             cert.Issuer.ToRDNSequence().String(),
-			NotBefore:          webcertificate.CertTime{Value: cert.NotBefore.UTC().Format(dateFormat), Timestamp: cert.NotBefore.Unix()},
-			NotAfter:           webcertificate.CertTime{Value: cert.NotAfter.UTC().Format(dateFormat), Timestamp: cert.NotAfter.Unix()},
-			Subject:            cert.Subject.ToRDNSequence().String(),
-			PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
-			AlternativeNames:   cert.DNSNames,
+		o.ChainInfo = certinfo.GetChainInfo(cert, chain)
+		o.KeyInfo = checkKey(cert, source)
+		if ctx.Bool("ocsp") {
+			o.Ocsp = certinfo.CheckOcsp(cert, certinfo.FindIssuer(cert, chain, source.Bundle.Certificates[1:]), ctx.String("ocsp-url"))
 		}
-		o.Result = getValidationResult(
-			cert, x509.VerifyOptions{Roots: rootPool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}},
-			cert.Subject.ToRDNSequence().String(), cert.Issuer.ToRDNSequence().String(),
-		)
-
-		o.Sha1Fingerprint = fmt.Sprintf("%x", sha1.Sum(cert.Raw))
-		o.Sha256Fingerprint = fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
 		output = append(output, o)
-		return nil
-	})
-	if err != nil {
-		return err
 	}
 
 	//	b, err := json.MarshalIndent(output, "", "  ")
@@ -3,8 +3,12 @@ module kubecert-checker
 go 1.23.5
 
 require (
+	github.com/Elbandi/zabbix-checker/common v0.0.0-00010101000000-000000000000
+	github.com/Elbandi/zabbix-checker/common/certinfo v0.0.0-00010101000000-000000000000
 	github.com/urfave/cli/v2 v2.27.5
+	golang.org/x/crypto v0.32.0
 	golang.zabbix.com/agent2 v0.0.0-20250225074525-c34078a4563f
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,3 +19,7 @@ require (
 )
 
 replace golang.zabbix.com/agent2 v0.0.0-20250225074525-c34078a4563f => github.com/zabbix/zabbix/src/go v0.0.0-20250225074525-c34078a4563f
+
+replace github.com/Elbandi/zabbix-checker/common => ../common
+
+replace github.com/Elbandi/zabbix-checker/common/certinfo => ../common/certinfo
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"math"
+	"time"
+)
+
+const maxChainDepth = 8
+
+type CaTree struct {
+	Name           string   `json:"name"`
+	Subject        string   `json:"subject"`
+	Parent         string   `json:"parent,omitempty"`
+	NotAfter       int64    `json:"not_after"`
+	Children       []string `json:"children"`
+	Leaves         []string `json:"leaves"`
+	EarliestExpiry int64    `json:"earliest_expiry"`
+	EarliestName   string   `json:"earliest_name"`
+	Days           int      `json:"days"`
+}
+
+// caNode is a certificate of the hierarchy, CAs are deduplicated by
+// fingerprint as the same CA is in many bundles
+type caNode struct {
+	Name   string
+	Cert   *x509.Certificate
+	Parent *caNode
+}
+
+var caTreeCommand = cli.Command{
+	Name:   "catree",
+	Usage:  "catree [LAYOUT:]/var/lib/rancher/k3s...",
+	Action: cmdCaTree,
+}
+
+// findParent returns the CA which signed the certificate
+func findParent(cert *x509.Certificate, cas []*caNode) *caNode {
+	for _, ca := range cas {
+		if ca.Cert == cert || bytes.Equal(ca.Cert.Raw, cert.Raw) {
+			continue
+		}
+		if bytes.Equal(cert.RawIssuer, ca.Cert.RawSubject) && cert.CheckSignatureFrom(ca.Cert) == nil {
+			return ca
+		}
+	}
+	return nil
+}
+
+// buildHierarchy returns the CAs and the leaves of the sources. CAs are named
+// after their file, CAs only found in bundles after their subject.
+func buildHierarchy(sources []certSource) ([]*caNode, []*caNode) {
+	cas := make([]*caNode, 0)
+	leaves := make([]*caNode, 0)
+	seen := make(map[[sha256.Size]byte]bool)
+	for _, source := range sources {
+		if source.Err != nil {
+			continue
+		}
+		certs := source.Bundle.Certificates
+		if len(certs) == 0 {
+			continue
+		}
+		if !certs[0].IsCA {
+			leaves = append(leaves, &caNode{Name: source.Name, Cert: certs[0]})
+			continue
+		}
+		if fp := sha256.Sum256(certs[0].Raw); !seen[fp] {
+			seen[fp] = true
+			cas = append(cas, &caNode{Name: source.Name, Cert: certs[0]})
+		}
+	}
+	for _, source := range sources {
+		if source.Err != nil {
+			continue
+		}
+		for _, cert := range source.Bundle.Certificates {
+			if fp := sha256.Sum256(cert.Raw); cert.IsCA && !seen[fp] {
+				seen[fp] = true
+				cas = append(cas, &caNode{Name: cert.Subject.ToRDNSequence().String(), Cert: cert})
+			}
+		}
+	}
+	for _, node := range cas {
+		node.Parent = findParent(node.Cert, cas)
+	}
+	for _, node := range leaves {
+		node.Parent = findParent(node.Cert, cas)
+	}
+	return cas, leaves
+}
+
+// dependsOn reports whether the node is below the CA, the depth is limited
+// as cross signed CAs may form a loop
+func dependsOn(node, ca *caNode) bool {
+	p := node.Parent
+	for depth := 0; p != nil && depth < maxChainDepth; depth++ {
+		if p == ca {
+			return true
+		}
+		p = p.Parent
+	}
+	return false
+}
+
+func cmdCaTree(ctx *cli.Context) error {
+	sources, err := collectSources(ctx, ctx.String("layout"), "*")
+	if err != nil {
+		return err
+	}
+	cas, leaves := buildHierarchy(sources)
+	now := time.Now()
+	output := make([]CaTree, 0)
+	for _, ca := range cas {
+		tree := CaTree{
+			Name:           ca.Name,
+			Subject:        ca.Cert.Subject.ToRDNSequence().String(),
+			NotAfter:       ca.Cert.NotAfter.Unix(),
+			Children:       make([]string, 0),
+			Leaves:         make([]string, 0),
+			EarliestExpiry: ca.Cert.NotAfter.Unix(),
+			EarliestName:   ca.Name,
+		}
+		if ca.Parent != nil {
+			tree.Parent = ca.Parent.Name
+		}
+		for _, node := range cas {
+			if node.Parent == ca {
+				tree.Children = append(tree.Children, node.Name)
+			}
+			if dependsOn(node, ca) && node.Cert.NotAfter.Unix() < tree.EarliestExpiry {
+				tree.EarliestExpiry = node.Cert.NotAfter.Unix()
+				tree.EarliestName = node.Name
+			}
+		}
+		for _, node := range leaves {
+			if !dependsOn(node, ca) {
+				continue
+			}
+			tree.Leaves = append(tree.Leaves, node.Name)
+			if node.Cert.NotAfter.Unix() < tree.EarliestExpiry {
+				tree.EarliestExpiry = node.Cert.NotAfter.Unix()
+				tree.EarliestName = node.Name
+			}
+		}
+		tree.Days = int(math.Floor(time.Unix(tree.EarliestExpiry, 0).Sub(now).Hours() / 24))
+		output = append(output, tree)
+	}
+
+	b, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+
+	return nil
+}
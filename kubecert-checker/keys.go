@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/x509"
+	"github.com/Elbandi/zabbix-checker/common/certinfo"
+	"os"
+)
+
+const (
+	keyOk       = "ok"
+	keyMismatch = "mismatch"
+	keyMissing  = "missing"
+	keyError    = "error"
+)
+
+type KeyInfo struct {
+	KeyFile          string `json:"key_file,omitempty"`
+	Key              string `json:"key"`
+	KeyWorldReadable bool   `json:"key_world_readable"`
+	KeyError         string `json:"key_error,omitempty"`
+}
+
+// checkKey pairs the certificate with its private key, the key is in the
+// certificate bundle itself or in a separate file. CA certificates of
+// kubeconfig files and secrets have none.
+func checkKey(cert *x509.Certificate, source certSource) KeyInfo {
+	keys, keyFile := source.Bundle, source.File
+	if len(keys.Keys) == 0 {
+		if len(source.Key) == 0 {
+			return KeyInfo{Key: keyMissing}
+		}
+		keyFile = source.KeyFile
+		var err error
+		keys, err = certinfo.Parse(source.Key, "")
+		if err != nil {
+			return KeyInfo{KeyFile: keyFile, Key: keyError, KeyError: err.Error()}
+		}
+	}
+	info := KeyInfo{KeyFile: keyFile, Key: keyOk}
+	if stat, err := os.Stat(keyFile); err == nil {
+		info.KeyWorldReadable = stat.Mode().Perm()&0004 != 0
+	}
+	if len(keys.Keys) == 0 {
+		info.Key = keyError
+		info.KeyError = "no private key found"
+	} else if !keys.KeyMatches(cert) {
+		info.Key = keyMismatch
+	}
+	return info
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Elbandi/zabbix-checker/common/certinfo"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	layoutK3s        = "k3s"
+	layoutKubeadm    = "kubeadm"
+	layoutKubelet    = "kubelet"
+	layoutKubeconfig = "kubeconfig"
+	layoutSecrets    = "secrets"
+	layoutFile       = "file"
+)
+
+var layouts = []string{layoutK3s, layoutKubeadm, layoutKubelet, layoutKubeconfig, layoutSecrets, layoutFile}
+
+// certSource is one certificate bundle found in a path, File is where it came
+// from. Key is the private key of the certificate if found, KeyFile holds it.
+type certSource struct {
+	Name    string
+	File    string
+	Data    []byte
+	Bundle  *certinfo.Bundle
+	Err     error
+	KeyFile string
+	Key     []byte
+}
+
+type kubeconfig struct {
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+type kubeObject struct {
+	Kind     string `yaml:"kind"`
+	Type     string `yaml:"type"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+	StringData map[string]string `yaml:"stringData"`
+	Items      []kubeObject      `yaml:"items"`
+}
+
+// splitRoot separates the optional "LAYOUT:" prefix of a path
+func splitRoot(root, defaultLayout string) (string, string) {
+	for _, layout := range layouts {
+		if strings.HasPrefix(root, layout+":") {
+			return layout, strings.TrimPrefix(root, layout+":")
+		}
+	}
+	return defaultLayout, root
+}
+
+// listFiles returns the files of a directory tree with one of the suffixes,
+// or the path itself if it is a file
+func listFiles(root string, suffixes ...string) ([]string, error) {
+	stat, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return []string{root}, nil
+	}
+	files := make([]string, 0)
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(path, suffix) {
+				files = append(files, path)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// fileSources reads the certificate files of a directory, the name is the
+// path relative to the directory
+func fileSources(dir string, suffixes ...string) ([]certSource, error) {
+	files, err := listFiles(dir, suffixes...)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]certSource, 0)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate '%s' file: %w", file, err)
+		}
+		if !bytes.Contains(data, []byte("-----BEGIN CERTIFICATE-----")) {
+			// key only pem files in the kubelet directory
+			continue
+		}
+		sources = append(sources, withKeyFile(certSource{Name: strings.TrimPrefix(file, dir+string(os.PathSeparator)), File: file, Data: data}))
+	}
+	return sources, nil
+}
+
+// withKeyFile adds the key file next to the certificate, kubelet-client-current.pem
+// has the key in the bundle itself
+func withKeyFile(source certSource) certSource {
+	keyFile := strings.TrimSuffix(source.File, filepath.Ext(source.File)) + ".key"
+	if key, err := ioutil.ReadFile(keyFile); err == nil && keyFile != source.File {
+		source.KeyFile, source.Key = keyFile, key
+	}
+	return source
+}
+
+func decodeData(value string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+}
+
+// kubeconfigSources returns the client certificates and the cluster CAs
+// embedded in a kubeconfig file
+func kubeconfigSources(file string) ([]certSource, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var config kubeconfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig '%s': %w", file, err)
+	}
+	base := filepath.Base(file)
+	sources := make([]certSource, 0)
+	for _, cluster := range config.Clusters {
+		if len(cluster.Cluster.CertificateAuthorityData) == 0 {
+			continue
+		}
+		data, err := decodeData(cluster.Cluster.CertificateAuthorityData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate-authority-data of cluster %s in '%s': %w", cluster.Name, file, err)
+		}
+		sources = append(sources, certSource{Name: base + "/clusters/" + cluster.Name, File: file, Data: data})
+	}
+	for _, user := range config.Users {
+		if len(user.User.ClientCertificateData) == 0 {
+			continue
+		}
+		data, err := decodeData(user.User.ClientCertificateData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client-certificate-data of user %s in '%s': %w", user.Name, file, err)
+		}
+		source := certSource{Name: base + "/users/" + user.Name, File: file, Data: data}
+		if len(user.User.ClientKeyData) > 0 {
+			source.KeyFile = file
+			source.Key, err = decodeData(user.User.ClientKeyData)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client-key-data of user %s in '%s': %w", user.Name, file, err)
+			}
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// secretValue returns a stringData or a base64 data entry of a secret
+func secretValue(object kubeObject, key string) ([]byte, error) {
+	if value, ok := object.StringData[key]; ok {
+		return []byte(value), nil
+	}
+	if value, ok := object.Data[key]; ok {
+		return decodeData(value)
+	}
+	return nil, nil
+}
+
+// secretSources returns the tls.crt and ca.crt entries of kubernetes.io/tls
+// secrets, List objects and multi document files are flattened
+func secretSources(file string, objects []kubeObject) ([]certSource, error) {
+	sources := make([]certSource, 0)
+	for _, object := range objects {
+		if object.Kind == "List" || strings.HasSuffix(object.Kind, "List") {
+			s, err := secretSources(file, object.Items)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, s...)
+			continue
+		}
+		if object.Kind != "Secret" || object.Type != "kubernetes.io/tls" {
+			continue
+		}
+		name := object.Metadata.Name
+		if len(object.Metadata.Namespace) > 0 {
+			name = object.Metadata.Namespace + "/" + name
+		}
+		for _, key := range []string{"tls.crt", "ca.crt"} {
+			data, err := secretValue(object, key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s of secret %s in '%s': %w", key, name, file, err)
+			}
+			if len(data) == 0 {
+				continue
+			}
+			source := certSource{Name: name, File: file, Data: data}
+			if key == "tls.crt" {
+				source.KeyFile = file
+				source.Key, err = secretValue(object, "tls.key")
+				if err != nil {
+					return nil, fmt.Errorf("invalid tls.key of secret %s in '%s': %w", name, file, err)
+				}
+			} else {
+				source.Name += "/" + key
+			}
+			sources = append(sources, source)
+		}
+	}
+	return sources, nil
+}
+
+func manifestSources(file string) ([]certSource, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]kubeObject, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var object kubeObject
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse manifest '%s': %w", file, err)
+		}
+		objects = append(objects, object)
+	}
+	return secretSources(file, objects)
+}
+
+// findSources lists the certificates of a path:
+//
+//	k3s:        <path>/server/tls/**/*.crt
+//	kubeadm:    <path>/pki/**/*.crt
+//	kubelet:    <path>/*.crt and *.pem (/var/lib/kubelet/pki)
+//	kubeconfig: kubeconfig file, or *.conf, *.yaml, *.kubeconfig files of a directory
+//	secrets:    yaml or json manifest file or directory with kubernetes.io/tls secrets
+//	file:       any certificate file, or the files of a directory matching the glob
+func findSources(root, layout, glob string) ([]certSource, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("invalid %s path: %s", layout, root)
+	}
+	switch layout {
+	case layoutK3s:
+		return fileSources(filepath.Join(root, "server", "tls"), ".crt")
+	case layoutKubeadm:
+		return fileSources(filepath.Join(root, "pki"), ".crt")
+	case layoutKubelet:
+		return fileSources(root, ".crt", ".pem")
+	case layoutKubeconfig:
+		files, err := listFiles(root, ".conf", ".yaml", ".yml", ".kubeconfig")
+		if err != nil {
+			return nil, err
+		}
+		sources := make([]certSource, 0)
+		for _, file := range files {
+			s, err := kubeconfigSources(file)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, s...)
+		}
+		return sources, nil
+	case layoutFile:
+		files, err := certinfo.FindFiles(root, glob)
+		if err != nil {
+			return nil, err
+		}
+		sources := make([]certSource, 0, len(files))
+		for _, file := range files {
+			if certinfo.IsKeyFile(file) {
+				// key files next to the certificates
+				continue
+			}
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read certificate '%s' file: %w", file, err)
+			}
+			sources = append(sources, withKeyFile(certSource{Name: file, File: file, Data: data}))
+		}
+		return sources, nil
+	case layoutSecrets:
+		files, err := listFiles(root, ".yaml", ".yml", ".json")
+		if err != nil {
+			return nil, err
+		}
+		sources := make([]certSource, 0)
+		for _, file := range files {
+			s, err := manifestSources(file)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, s...)
+		}
+		return sources, nil
+	}
+	return nil, fmt.Errorf("unknown layout %s", layout)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/Elbandi/zabbix-checker/common/urfavecli"
 	"github.com/urfave/cli/v2"
 	"log"
 	"os"
@@ -23,8 +24,36 @@ func main() {
 				Email: "elso.andras@gmail.com",
 			},
 		},
+		Flags: []cli.Flag{
+			&cli.GenericFlag{
+				Name:  "layout",
+				Usage: "Layout of the paths without LAYOUT: prefix (default: file for certinfo)",
+				Value: &urfavecli.EnumValue{
+					Enum:    layouts,
+					Default: layoutK3s,
+				},
+			},
+			&cli.StringFlag{
+				Name:    "password",
+				Usage:   "Password of PKCS#12 certificate files",
+				EnvVars: []string{"PKCS12_PASSWORD"},
+			},
+			&cli.StringFlag{
+				Name:  "ca-file",
+				Usage: "Trusted root certificates, PEM file or directory (default: self signed CAs of the scanned certificates)",
+			},
+			&cli.BoolFlag{
+				Name:  "ocsp",
+				Usage: "Check the revocation status with OCSP",
+			},
+			&cli.StringFlag{
+				Name:  "ocsp-url",
+				Usage: "OCSP responder url instead of the one in the certificate",
+			},
+		},
 		Commands: []*cli.Command{
 			&certInfoCommand,
+			&caTreeCommand,
 		},
 	}
 
@@ -4,6 +4,7 @@ import (
 	"github.com/bitbandi/go-nicehash-api"
 	"github.com/Elbandi/zabbix-checker/common/lld"
 	"github.com/Elbandi/zabbix-checker/common/filemutex"
+	"github.com/Elbandi/zabbix-checker/common/nicehashv2"
 	"crypto/tls"
 	"errors"
 	"flag"
@@ -30,6 +31,7 @@ var (
 	// flags
 	debug     bool
 	userAgent string
+	baseurl   string
 )
 
 func FindOrder(id uint64, orders []nicehash.MyOrders) *nicehash.MyOrders {
@@ -230,6 +232,8 @@ func main() {
 	proxyPtr := flag.String("proxy", "", "socks proxy")
 	flag.BoolVar(&debug, "debug", false, "enable request/response dump")
 	flag.StringVar(&userAgent, "user-agent", defaultUserAgent, "http client user agent")
+	v2Ptr := flag.Bool("v2", false, "use the api v2 with organization id, api key and secret")
+	flag.StringVar(&baseurl, "base", nicehashv2.DefaultBaseURL, "nicehash api v2 base url")
 	flag.Parse()
 	log.SetOutput(os.Stderr)
 
@@ -251,6 +255,11 @@ func main() {
 		log.Printf("Set proxy to %s", proxyURL)
 	}
 
+	if *v2Ptr {
+		runV2(flag.Args())
+		return
+	}
+
 	switch flag.Arg(0) {
 	case "discovery":
 		switch flag.NArg() {
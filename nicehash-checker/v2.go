@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Elbandi/zabbix-checker/common/filemutex"
+	"github.com/Elbandi/zabbix-checker/common/lld"
+	"github.com/Elbandi/zabbix-checker/common/nicehashv2"
+)
+
+// v2Timeout limits a whole api v2 request, the agent waits for the item
+const v2Timeout = 30 * time.Second
+
+var ErrAlgoNotFound = errors.New("Algo not found")
+
+// V2Order is the output of the orders action
+type V2Order struct {
+	Id           string  `json:"id"`
+	Market       string  `json:"market"`
+	Algorithm    string  `json:"algorithm"`
+	Type         string  `json:"type"`
+	Status       string  `json:"status"`
+	Alive        bool    `json:"alive"`
+	Pool         string  `json:"pool"`
+	Host         string  `json:"host"`
+	Port         uint    `json:"port"`
+	User         string  `json:"user"`
+	Price        float64 `json:"price"`
+	Limit        float64 `json:"limit"`
+	Amount       float64 `json:"amount"`
+	Remaining    float64 `json:"remaining"`
+	Payed        float64 `json:"payed"`
+	Speed        float64 `json:"speed"`
+	SpeedPercent float64 `json:"speedpercent"`
+	SpeedUnit    string  `json:"speedunit"`
+	Rigs         int     `json:"rigs"`
+}
+
+// V2Rig is the output of the rigs action
+type V2Rig struct {
+	Id            string             `json:"id"`
+	Name          string             `json:"name"`
+	Status        string             `json:"status"`
+	Unpaid        float64            `json:"unpaid"`
+	Profitability float64            `json:"profitability"`
+	Devices       int                `json:"devices"`
+	Workers       []V2Worker         `json:"workers"`
+	Speeds        map[string]float64 `json:"speeds"`
+}
+
+// V2Worker is the stat of a rig on one algorithm and market
+type V2Worker struct {
+	Algorithm     string  `json:"algorithm"`
+	Market        string  `json:"market"`
+	Accepted      float64 `json:"accepted"`
+	Rejected      float64 `json:"rejected"`
+	Unpaid        float64 `json:"unpaid"`
+	Profitability float64 `json:"profitability"`
+}
+
+// V2Balance is the output of the balances action
+type V2Balance struct {
+	Currency  string  `json:"currency"`
+	Total     float64 `json:"total"`
+	Available float64 `json:"available"`
+	Pending   float64 `json:"pending"`
+	BtcRate   float64 `json:"btcrate"`
+}
+
+func newV2Client(orgId, apiKey, apiSecret string) *nicehashv2.Client {
+	client := nicehashv2.NewClient(&http.Client{Timeout: v2Timeout}, baseurl, orgId, apiKey, apiSecret, userAgent)
+	client.SetDebug(debug)
+	return client
+}
+
+func v2Lock(orgId string) *filemutex.FileMutex {
+	return filemutex.MakeFileMutex(filepath.Join(os.TempDir(), "nicehash-v2-"+orgId))
+}
+
+func v2GetMyOrders(request []string) ([]nicehashv2.Order, error) {
+	lock := v2Lock(request[0])
+	lock.Lock()
+	defer lock.Unlock()
+	return newV2Client(request[0], request[1], request[2]).GetMyOrders("", "")
+}
+
+func v2FindOrder(request []string) (*nicehashv2.Order, error) {
+	orders, err := v2GetMyOrders(request)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		if order.Id == request[3] {
+			return &order, nil
+		}
+	}
+	return nil, ErrOrderNotFound
+}
+
+// V2DiscoverOrders is a DiscoveryItemHandlerFunc for key `nicehash.discovery` which returns JSON
+// encoded discovery data for all active orders
+func V2DiscoverOrders(request []string) (lld.DiscoveryData, error) {
+	orders, err := v2GetMyOrders(request)
+	if err != nil {
+		return nil, err
+	}
+	d := make(lld.DiscoveryData, 0)
+	for _, order := range orders {
+		item := make(lld.DiscoveryItem, 0)
+		item["ID"] = order.Id
+		item["TYPE"] = order.Type.Code
+		item["ALGO"] = order.Algorithm.Algorithm
+		item["LOCATION"] = order.Market
+		item["POOL"] = order.Pool.Name
+		item["HOST"] = order.Pool.StratumHostname
+		item["PORT"] = strconv.FormatUint(uint64(order.Pool.StratumPort), 10)
+		item["USER"] = order.Pool.Username
+		item["SPEED"] = strconv.FormatFloat(float64(order.Limit), 'f', -1, 64)
+		item["NAME"] = fmt.Sprintf("%.1s %s", order.Type.Code, order.Id)
+		d = append(d, item)
+	}
+	return d, nil
+}
+
+// V2QueryOrders is a StringItemHandlerFunc for key `nicehash.orders` which returns the active
+// orders as JSON
+func V2QueryOrders(request []string) (string, error) {
+	orders, err := v2GetMyOrders(request)
+	if err != nil {
+		return "", err
+	}
+	output := make([]V2Order, 0, len(orders))
+	for _, order := range orders {
+		output = append(output, V2Order{
+			Id:           order.Id,
+			Market:       order.Market,
+			Algorithm:    order.Algorithm.Algorithm,
+			Type:         order.Type.Code,
+			Status:       order.Status.Code,
+			Alive:        order.Alive,
+			Pool:         order.Pool.Name,
+			Host:         order.Pool.StratumHostname,
+			Port:         order.Pool.StratumPort,
+			User:         order.Pool.Username,
+			Price:        float64(order.Price),
+			Limit:        float64(order.Limit),
+			Amount:       float64(order.Amount),
+			Remaining:    float64(order.AvailableAmount),
+			Payed:        float64(order.PayedAmount),
+			Speed:        float64(order.AcceptedCurrentSpeed),
+			SpeedPercent: order.SpeedPercent(),
+			SpeedUnit:    order.DisplayMarketFactor,
+			Rigs:         order.RigsCount,
+		})
+	}
+	b, err := json.Marshal(output)
+	return string(b), err
+}
+
+// V2QueryRigs is a StringItemHandlerFunc for key `nicehash.rigs` which returns the mining rigs
+// and their worker stats as JSON
+func V2QueryRigs(request []string) (string, error) {
+	lock := v2Lock(request[0])
+	lock.Lock()
+	defer lock.Unlock()
+	rigs, err := newV2Client(request[0], request[1], request[2]).GetRigs()
+	if err != nil {
+		return "", err
+	}
+	output := make([]V2Rig, 0, len(rigs.MiningRigs))
+	for _, rig := range rigs.MiningRigs {
+		r := V2Rig{
+			Id:            rig.RigId,
+			Name:          rig.Name,
+			Status:        rig.MinerStatus,
+			Unpaid:        float64(rig.UnpaidAmount),
+			Profitability: float64(rig.Profitability),
+			Devices:       len(rig.Devices),
+			Workers:       make([]V2Worker, 0, len(rig.Stats)),
+			Speeds:        make(map[string]float64),
+		}
+		for _, stat := range rig.Stats {
+			r.Workers = append(r.Workers, V2Worker{
+				Algorithm:     stat.Algorithm.EnumName,
+				Market:        stat.Market,
+				Accepted:      float64(stat.SpeedAccepted),
+				Rejected:      float64(stat.SpeedRejectedTotal),
+				Unpaid:        float64(stat.UnpaidAmount),
+				Profitability: float64(stat.Profitability),
+			})
+		}
+		for _, device := range rig.Devices {
+			for _, speed := range device.Speeds {
+				r.Speeds[speed.Algorithm] += float64(speed.Speed)
+			}
+		}
+		output = append(output, r)
+	}
+	b, err := json.Marshal(output)
+	return string(b), err
+}
+
+// V2QueryBalances is a StringItemHandlerFunc for key `nicehash.balances` which returns the wallet
+// balances as JSON, the total is in BTC
+func V2QueryBalances(request []string) (string, error) {
+	lock := v2Lock(request[0])
+	lock.Lock()
+	defer lock.Unlock()
+	accounts, err := newV2Client(request[0], request[1], request[2]).GetAccounts()
+	if err != nil {
+		return "", err
+	}
+	output := make([]V2Balance, 0, len(accounts.Currencies)+1)
+	for _, balance := range append([]nicehashv2.Balance{accounts.Total}, accounts.Currencies...) {
+		output = append(output, V2Balance{
+			Currency:  balance.Currency,
+			Total:     float64(balance.TotalBalance),
+			Available: float64(balance.Available),
+			Pending:   float64(balance.Pending),
+			BtcRate:   float64(balance.BtcRate),
+		})
+	}
+	output[0].Currency = "TOTAL"
+	output[0].BtcRate = 1
+	b, err := json.Marshal(output)
+	return string(b), err
+}
+
+// V2QueryProfitability is a DoubleItemHandlerFunc for key `nicehash.profitability` which returns
+// the paying price for algo.
+func V2QueryProfitability(request []string) (float64, error) {
+	algorithms, err := newV2Client("", "", "").GetAlgorithms()
+	if err != nil {
+		return 0.00, err
+	}
+	for _, algo := range algorithms {
+		if strings.EqualFold(algo.Algorithm, request[0]) {
+			return float64(algo.Paying), nil
+		}
+	}
+	return 0.00, ErrAlgoNotFound
+}
+
+// V2QueryLowPrice is a DoubleItemHandlerFunc for key `nicehash.lowprice` which returns the lowest
+// price for public orders of the algo on a market.
+func V2QueryLowPrice(request []string) (float64, error) {
+	book, err := newV2Client("", "", "").GetOrderBook(strings.ToUpper(request[0]))
+	if err != nil {
+		return 0.00, err
+	}
+	minprice, ok := nicehashv2.LowPrice(book[strings.ToUpper(request[1])])
+	if !ok {
+		return 0.00, nil
+	}
+	return minprice, nil
+}
+
+func runV2(args []string) {
+	usage := map[string]string{
+		"discovery":     "ORGID APIKEY APISECRET",
+		"orders":        "ORGID APIKEY APISECRET",
+		"rigs":          "ORGID APIKEY APISECRET",
+		"balances":      "ORGID APIKEY APISECRET",
+		"profitability": "ALGO",
+		"lowprice":      "ALGO MARKET",
+		"price":         "ORGID APIKEY APISECRET ORDERID",
+		"btcavail":      "ORGID APIKEY APISECRET ORDERID",
+		"status":        "ORGID APIKEY APISECRET ORDERID",
+		"speedpercent":  "ORGID APIKEY APISECRET ORDERID",
+	}
+	if len(args) == 0 {
+		log.Fatal("You must specify one of the following action: 'discovery', 'orders', 'rigs', 'balances', 'profitability', 'lowprice', 'price', 'status', 'btcavail' or 'speedpercent'.")
+	}
+	action, request := args[0], args[1:]
+	params, ok := usage[action]
+	if !ok {
+		log.Fatal("You must specify one of the following action: 'discovery', 'orders', 'rigs', 'balances', 'profitability', 'lowprice', 'price', 'status', 'btcavail' or 'speedpercent'.")
+	}
+	if len(request) != len(strings.Fields(params)) {
+		log.Fatalf("Usage: %s -v2 %s %s", os.Args[0], action, params)
+	}
+
+	var v interface{}
+	var err error
+	switch action {
+	case "discovery":
+		var d lld.DiscoveryData
+		if d, err = V2DiscoverOrders(request); err == nil {
+			v = d.Json()
+		}
+	case "orders":
+		v, err = V2QueryOrders(request)
+	case "rigs":
+		v, err = V2QueryRigs(request)
+	case "balances":
+		v, err = V2QueryBalances(request)
+	case "profitability":
+		v, err = V2QueryProfitability(request)
+	case "lowprice":
+		v, err = V2QueryLowPrice(request)
+	default:
+		var order *nicehashv2.Order
+		if order, err = v2FindOrder(request); err != nil {
+			break
+		}
+		switch action {
+		case "price":
+			v = float64(order.Price)
+		case "btcavail":
+			v = float64(order.AvailableAmount)
+		case "status":
+			if order.Alive {
+				v = "Alive"
+			} else {
+				v = "Dead"
+			}
+		case "speedpercent":
+			v = order.SpeedPercent()
+		}
+	}
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+	fmt.Print(v)
+}
@@ -3,13 +3,17 @@ package main
 import (
 	"github.com/bitbandi/go-nicehash-api"
 	"github.com/Elbandi/zabbix-checker/common/lld"
+	"github.com/Elbandi/zabbix-checker/common/nicehashv2"
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"strconv"
 	"time"
 	"golang.org/x/net/http2"
@@ -20,8 +24,14 @@ const defaultUserAgent = "nicehash-checker/1.0"
 var (
 	ApiId string
 	ApiKey string
+	OrgId string
+	ApiSecret string
 	UpdateInterval uint
+	FullScanInterval uint
+	Recommend bool
+	PriceStep float64
 	hostname string
+	zabbixServer string
 	baseurl string
 	debug bool
 	userAgent string
@@ -29,11 +39,17 @@ var (
 
 func init() {
 	flag.BoolVar(&debug, "debug", false, "Print debug infos")
-	flag.StringVar(&baseurl, "base", "https://www.nicehash.com", "nicehash base domain")
+	flag.StringVar(&baseurl, "base", "https://www.nicehash.com", "nicehash base domain (api v2: "+nicehashv2.DefaultBaseURL+")")
 	flag.StringVar(&ApiId, "apiid", "", "Nicehash api id")
 	flag.StringVar(&ApiKey, "apikey", "", "Nicehash api key")
-	flag.UintVar(&UpdateInterval, "updateinterval", 300, "Update interval")
+	flag.StringVar(&OrgId, "orgid", "", "Nicehash organization id, enables the api v2")
+	flag.StringVar(&ApiSecret, "apisecret", "", "Nicehash api v2 secret")
+	flag.UintVar(&UpdateInterval, "updateinterval", 0, "Run as a daemon and update in this interval in seconds, 0 runs only once")
+	flag.UintVar(&FullScanInterval, "fullscan", 12, "Scan every algo/location after this many updates, the others query only the known pairs")
+	flag.BoolVar(&Recommend, "recommend", false, "Send the suggested price, the hours left and the overpaying flag of the orders")
+	flag.Float64Var(&PriceStep, "pricestep", 0.0001, "Suggested price above the lowest price of the market")
 	flag.StringVar(&hostname, "hostname", "", "zabbix hostname")
+	flag.StringVar(&zabbixServer, "zabbix-server", "", "send the values to this zabbix server instead of printing them")
 	flag.StringVar(&userAgent, "user-agent", defaultUserAgent, "http client user agent")
 }
 
@@ -65,86 +81,124 @@ func (t *myTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 	return response, err
 }
 
+// pair is an algo and location where orders can be placed
+type pair struct {
+	Algo     nicehash.AlgoType
+	Location nicehash.Location
+}
 
-func main() {
-	var allorders []nicehash.MyOrders
+// allPairs returns every algo and location pair
+func allPairs() []pair {
+	var pairs []pair
+	for loc := nicehash.LocationNiceHash; loc < nicehash.LocationMAX; loc++ {
+		for algo := nicehash.AlgoTypeScrypt; algo < nicehash.AlgoTypeMAX; algo++ {
+			pairs = append(pairs, pair{algo, loc})
+		}
+	}
+	return pairs
+}
 
-	proxyPtr := flag.String("proxy", "", "socks proxy")
-	flag.Parse()
-	log.SetOutput(os.Stderr)
+// orderScanner writes the sender lines of the orders
+type orderScanner interface {
+	scan(w io.Writer)
+}
 
-	if *proxyPtr != "" {
-		proxyURL, err := url.Parse("socks5://" + *proxyPtr)
+// scanner remembers the pairs which had orders, so only these are queried
+// between the full scans. The orders of a pair are nil if its first query
+// failed, so it is not known whether it has any.
+type scanner struct {
+	client *nicehash.NicehashClient
+	known  []pair
+	orders map[pair][]nicehash.MyOrders
+	cycle  uint
+}
+
+// scan queries the orders of the pairs and writes the sender lines into w
+func (s *scanner) scan(w io.Writer) {
+	pairs := s.known
+	full := s.cycle == 0 || FullScanInterval <= 1 || s.cycle%FullScanInterval == 0
+	if full {
+		pairs = allPairs()
+	}
+	s.cycle++
+	if debug {
+		log.Printf("Scanning %d algo/location pairs (full: %v)", len(pairs), full)
+	}
+
+	var known []pair
+	var allorders []nicehash.MyOrders
+	pairOrders := make(map[pair][]nicehash.MyOrders)
+	orderPairs := make(map[uint64]pair)
+	complete := true
+	for i, p := range pairs {
+		if i > 0 {
+			time.Sleep(2 * time.Second)
+		}
+		orders, err := s.client.GetMyOrders(p.Algo, p.Location)
 		if err != nil {
-			log.Fatalf("Failed to parse proxy URL: %v", err)
+			log.Printf("Failed to get the orders of %s/%s: %v", p.Algo.ToString(), p.Location.ToString(), err)
+			// it could be a temporary error, the orders of the last update
+			// stay in the discovery, but their values are not sent
+			if prev, ok := s.orders[p]; ok || s.orders == nil {
+				known = append(known, p)
+				pairOrders[p] = prev
+				complete = complete && prev != nil
+			}
+			continue
 		}
-		log.Printf("Set proxy to %s", proxyURL)
-		transport, ok := http.DefaultTransport.(*http.Transport)
-		if !ok {
-			log.Fatalf("Failed to get the default http transport")
+		if len(orders) > 0 {
+			known = append(known, p)
+			pairOrders[p] = orders
 		}
-		http2.ConfigureTransport(transport)
-		http.DefaultTransport = &myTransport{
-			proxyURL: proxyURL,
-			rt:       transport,
+		allorders = append(allorders, orders...)
+		for _, order := range orders {
+			orderPairs[order.Id] = p
 		}
 	}
-
-	if len(ApiKey) == 0 || len(ApiKey) == 0 {
-		log.Fatalf("No api id/key specified")
-	}
-
-	var pairs []struct{ nicehash.AlgoType; nicehash.Location }
-
-	client := nicehash.NewNicehashClient(nil, baseurl, ApiId, ApiKey, userAgent)
-	client.SetDebug(debug)
+	s.known = known
+	s.orders = pairOrders
 
 	discovery := make(lld.DiscoveryData, 0)
-	for loc := nicehash.LocationNiceHash; loc < nicehash.LocationMAX; loc ++ {
-		for algo := nicehash.AlgoTypeScrypt; algo < nicehash.AlgoTypeMAX; algo++ {
-			orders, err := client.GetMyOrders(algo, loc)
-			if err != nil {
-				continue
-			}
-			if len(orders) > 0 {
-				pairs = append(pairs, struct{ nicehash.AlgoType; nicehash.Location }{algo, loc})
-			}
-			allorders = append(allorders, orders...)
-			for _, order := range orders {
-				item := make(lld.DiscoveryItem, 0)
-				item["ID"] = strconv.FormatUint(order.Id, 10)
-				item["TYPE"] = order.Type.ToString()
-				item["ALGO"] = order.Algo.ToString()
-				item["LOCATION"] = loc.ToString()
-				item["HOST"] = order.PoolHost
-				item["PORT"] = strconv.FormatUint(uint64(order.PoolPort), 10)
-				item["USER"] = order.PoolUser
-				item["SPEED"] = strconv.FormatFloat(order.LimitSpeed, 'f', -1, 64)
-				item["NAME"] = fmt.Sprintf("%c #%d", order.Type.ToString()[0], order.Id)
-				discovery = append(discovery, item)
-			}
-			time.Sleep(2 * time.Second)
+	for _, p := range known {
+		for _, order := range pairOrders[p] {
+			item := make(lld.DiscoveryItem, 0)
+			item["ID"] = strconv.FormatUint(order.Id, 10)
+			item["TYPE"] = order.Type.ToString()
+			item["ALGO"] = order.Algo.ToString()
+			item["LOCATION"] = p.Location.ToString()
+			item["HOST"] = order.PoolHost
+			item["PORT"] = strconv.FormatUint(uint64(order.PoolPort), 10)
+			item["USER"] = order.PoolUser
+			item["SPEED"] = strconv.FormatFloat(order.LimitSpeed, 'f', -1, 64)
+			item["NAME"] = fmt.Sprintf("%c #%d", order.Type.ToString()[0], order.Id)
+			discovery = append(discovery, item)
 		}
 	}
-	fmt.Printf("\"%s\" \"nicehash.discovery\" %s\n", hostname, strconv.Quote(discovery.JsonLine()))
+	// a partial discovery would remove the orders of the unknown pairs
+	if complete {
+		fmt.Fprintf(w, "\"%s\" \"nicehash.discovery\" %s\n", hostname, strconv.Quote(discovery.JsonLine()))
+	} else {
+		log.Printf("Skipping the discovery, the orders of some pairs are unknown")
+	}
 
 	for _, order := range allorders {
-		fmt.Printf("\"%s\" \"nicehash.price[%d]\" \"%f\"\n", hostname, order.Id, order.Price)
-		fmt.Printf("\"%s\" \"nicehash.btcavail[%d]\" \"%f\"\n", hostname, order.Id, order.BtcAvail)
+		fmt.Fprintf(w, "\"%s\" \"nicehash.price[%d]\" \"%f\"\n", hostname, order.Id, order.Price)
+		fmt.Fprintf(w, "\"%s\" \"nicehash.btcavail[%d]\" \"%f\"\n", hostname, order.Id, order.BtcAvail)
 		if order.Alive {
-			fmt.Printf("\"%s\" \"nicehash.status[%d]\" \"Alive\"\n", hostname, order.Id)
+			fmt.Fprintf(w, "\"%s\" \"nicehash.status[%d]\" \"Alive\"\n", hostname, order.Id)
 		} else {
-			fmt.Printf("\"%s\" \"nicehash.status[%d]\" \"Dead\"\n", hostname, order.Id)
+			fmt.Fprintf(w, "\"%s\" \"nicehash.status[%d]\" \"Dead\"\n", hostname, order.Id)
 		}
 		var speedpercent float64
 		if speedpercent = 0.00; order.LimitSpeed > 0 {
 			speedpercent = 100.0 * float64(order.AcceptedSpeed) / order.LimitSpeed
 		}
-		fmt.Printf("\"%s\" \"nicehash.speedpercent[%d]\" \"%f\"\n", hostname, order.Id, speedpercent)
+		fmt.Fprintf(w, "\"%s\" \"nicehash.speedpercent[%d]\" \"%f\"\n", hostname, order.Id, speedpercent)
 	}
 
-	for _, pair := range pairs {
-		orders, err := client.GetOrders(pair.AlgoType, pair.Location)
+	lowprices := make(map[pair]float64)
+	for _, p := range known {
+		orders, err := s.client.GetOrders(p.Algo, p.Location)
 		if err != nil {
 			continue
 		}
@@ -155,7 +209,96 @@ func main() {
 			}
 		}
 		if minprice < math.MaxFloat64 {
-			fmt.Printf("\"%s\" \"nicehash.lowprice[%s,%s]\" \"%f\"\n", hostname, pair.Location.ToString(), pair.AlgoType.ToString(), minprice)
+			lowprices[p] = minprice
+			fmt.Fprintf(w, "\"%s\" \"nicehash.lowprice[%s,%s]\" \"%f\"\n", hostname, p.Location.ToString(), p.Algo.ToString(), minprice)
+		}
+	}
+
+	if !Recommend {
+		return
+	}
+	for _, order := range allorders {
+		lowprice, ok := lowprices[orderPairs[order.Id]]
+		if !ok {
+			continue
+		}
+		recommend(order.Price, order.BtcAvail, float64(order.AcceptedSpeed), lowprice, isFixed(order.Type.ToString())).write(w, strconv.FormatUint(order.Id, 10))
+	}
+}
+
+// send pushes the sender lines to the zabbix server, or prints them if no
+// server is given
+func send(data []byte) error {
+	if len(zabbixServer) == 0 {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	cmdSender := exec.Command("zabbix_sender", "-z", zabbixServer, "-i", "-")
+	cmdSender.Stdin = bytes.NewReader(data)
+	if debug {
+		cmdSender.Stdout = os.Stderr
+	}
+	cmdSender.Stderr = os.Stderr
+	return cmdSender.Run()
+}
+
+func main() {
+	proxyPtr := flag.String("proxy", "", "socks proxy")
+	flag.Parse()
+	log.SetOutput(os.Stderr)
+
+	if *proxyPtr != "" {
+		proxyURL, err := url.Parse("socks5://" + *proxyPtr)
+		if err != nil {
+			log.Fatalf("Failed to parse proxy URL: %v", err)
+		}
+		log.Printf("Set proxy to %s", proxyURL)
+		transport, ok := http.DefaultTransport.(*http.Transport)
+		if !ok {
+			log.Fatalf("Failed to get the default http transport")
+		}
+		http2.ConfigureTransport(transport)
+		http.DefaultTransport = &myTransport{
+			proxyURL: proxyURL,
+			rt:       transport,
+		}
+	}
+
+	var s orderScanner
+	if len(OrgId) > 0 {
+		if len(ApiKey) == 0 || len(ApiSecret) == 0 {
+			log.Fatalf("No api key/secret specified")
+		}
+		base := ""
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "base" {
+				base = baseurl
+			}
+		})
+		client := nicehashv2.NewClient(&http.Client{Timeout: v2Timeout}, base, OrgId, ApiKey, ApiSecret, userAgent)
+		client.SetDebug(debug)
+		s = &v2scanner{client: client}
+	} else {
+		if len(ApiId) == 0 || len(ApiKey) == 0 {
+			log.Fatalf("No api id/key specified")
+		}
+		client := nicehash.NewNicehashClient(nil, baseurl, ApiId, ApiKey, userAgent)
+		client.SetDebug(debug)
+		s = &scanner{client: client}
+	}
+	for {
+		start := time.Now()
+		var buf bytes.Buffer
+		s.scan(&buf)
+		if err := send(buf.Bytes()); err != nil {
+			log.Printf("Failed to send the values: %v", err)
+		}
+		if UpdateInterval == 0 {
+			break
+		}
+		// the scan time is part of the interval
+		if wait := time.Duration(UpdateInterval)*time.Second - time.Since(start); wait > 0 {
+			time.Sleep(wait)
 		}
 	}
 }
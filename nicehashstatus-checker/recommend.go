@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// recommendation is the suggested setting of an order, the prices are in BTC
+// per speed unit per day like the order prices
+type recommendation struct {
+	Price      float64
+	Hours      float64
+	Overpaying int
+}
+
+// recommend suggests the lowest alive price with workers plus the price step
+// and estimates the hours left until the available btc runs out at the
+// current speed, the hours are -1 if the order is not spending. Fixed orders
+// pay their own price, so they are never overpaying.
+func recommend(price, btcavail, speed, lowprice float64, fixed bool) recommendation {
+	r := recommendation{
+		Price: lowprice + PriceStep,
+		Hours: -1,
+	}
+	if spending := price * speed / 24; spending > 0 {
+		r.Hours = btcavail / spending
+	}
+	if !fixed && price > r.Price {
+		r.Overpaying = 1
+	}
+	return r
+}
+
+// isFixed reports whether the order type is the fixed one, the api v1 names
+// it Fixed and the api v2 FIXED
+func isFixed(orderType string) bool {
+	return strings.EqualFold(orderType, "fixed")
+}
+
+func (r recommendation) write(w io.Writer, id string) {
+	fmt.Fprintf(w, "\"%s\" \"nicehash.recommend.price[%s]\" \"%f\"\n", hostname, id, r.Price)
+	fmt.Fprintf(w, "\"%s\" \"nicehash.recommend.hours[%s]\" \"%f\"\n", hostname, id, r.Hours)
+	fmt.Fprintf(w, "\"%s\" \"nicehash.recommend.overpaying[%s]\" \"%d\"\n", hostname, id, r.Overpaying)
+}
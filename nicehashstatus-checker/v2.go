@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Elbandi/zabbix-checker/common/lld"
+	"github.com/Elbandi/zabbix-checker/common/nicehashv2"
+)
+
+// v2Timeout limits a whole api v2 request, a stuck one would block the
+// updates of the daemon
+const v2Timeout = 30 * time.Second
+
+// v2scanner queries the orders of the api v2, it lists every order with one
+// request, so there is nothing to remember between the updates
+type v2scanner struct {
+	client *nicehashv2.Client
+}
+
+func (s *v2scanner) scan(w io.Writer) {
+	orders, err := s.client.GetMyOrders("", "")
+	if err != nil {
+		log.Printf("Failed to get the orders: %v", err)
+		return
+	}
+
+	discovery := make(lld.DiscoveryData, 0)
+	algos := make(map[string][]string)
+	for _, order := range orders {
+		item := make(lld.DiscoveryItem, 0)
+		item["ID"] = order.Id
+		item["TYPE"] = order.Type.Code
+		item["ALGO"] = order.Algorithm.Algorithm
+		item["LOCATION"] = order.Market
+		item["HOST"] = order.Pool.StratumHostname
+		item["PORT"] = strconv.FormatUint(uint64(order.Pool.StratumPort), 10)
+		item["USER"] = order.Pool.Username
+		item["SPEED"] = strconv.FormatFloat(float64(order.Limit), 'f', -1, 64)
+		item["NAME"] = fmt.Sprintf("%.1s %s", order.Type.Code, order.Id)
+		discovery = append(discovery, item)
+		if !contains(algos[order.Algorithm.Algorithm], order.Market) {
+			algos[order.Algorithm.Algorithm] = append(algos[order.Algorithm.Algorithm], order.Market)
+		}
+	}
+	fmt.Fprintf(w, "\"%s\" \"nicehash.discovery\" %s\n", hostname, strconv.Quote(discovery.JsonLine()))
+
+	for _, order := range orders {
+		fmt.Fprintf(w, "\"%s\" \"nicehash.price[%s]\" \"%f\"\n", hostname, order.Id, order.Price)
+		fmt.Fprintf(w, "\"%s\" \"nicehash.btcavail[%s]\" \"%f\"\n", hostname, order.Id, order.AvailableAmount)
+		if order.Alive {
+			fmt.Fprintf(w, "\"%s\" \"nicehash.status[%s]\" \"Alive\"\n", hostname, order.Id)
+		} else {
+			fmt.Fprintf(w, "\"%s\" \"nicehash.status[%s]\" \"Dead\"\n", hostname, order.Id)
+		}
+		fmt.Fprintf(w, "\"%s\" \"nicehash.speedpercent[%s]\" \"%f\"\n", hostname, order.Id, order.SpeedPercent())
+	}
+
+	// the order book of an algo has every market
+	lowprices := make(map[string]float64)
+	var names []string
+	for algo := range algos {
+		names = append(names, algo)
+	}
+	sort.Strings(names)
+	for _, algo := range names {
+		book, err := s.client.GetOrderBook(algo)
+		if err != nil {
+			log.Printf("Failed to get the order book of %s: %v", algo, err)
+			continue
+		}
+		for _, market := range algos[algo] {
+			if minprice, ok := nicehashv2.LowPrice(book[market]); ok {
+				lowprices[market+","+algo] = minprice
+				fmt.Fprintf(w, "\"%s\" \"nicehash.lowprice[%s,%s]\" \"%f\"\n", hostname, market, algo, minprice)
+			}
+		}
+	}
+
+	if Recommend {
+		for _, order := range orders {
+			lowprice, ok := lowprices[order.Market+","+order.Algorithm.Algorithm]
+			if !ok {
+				continue
+			}
+			recommend(float64(order.Price), float64(order.AvailableAmount), float64(order.AcceptedCurrentSpeed), lowprice, isFixed(order.Type.Code)).write(w, order.Id)
+		}
+	}
+
+	accounts, err := s.client.GetAccounts()
+	if err != nil {
+		log.Printf("Failed to get the balances: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "\"%s\" \"nicehash.balance[TOTAL]\" \"%f\"\n", hostname, accounts.Total.TotalBalance)
+	for _, balance := range accounts.Currencies {
+		fmt.Fprintf(w, "\"%s\" \"nicehash.balance[%s]\" \"%f\"\n", hostname, balance.Currency, balance.TotalBalance)
+		fmt.Fprintf(w, "\"%s\" \"nicehash.balance.available[%s]\" \"%f\"\n", hostname, balance.Currency, balance.Available)
+	}
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
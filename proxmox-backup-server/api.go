@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultEndpoint = "127.0.0.1"
+
+// Client is a minimal client of the Proxmox Backup Server API, it
+// authenticates with an API token or with a ticket of the user password
+type Client struct {
+	baseURL string
+	header  http.Header
+	http    *http.Client
+}
+
+type apiResponse struct {
+	Data   json.RawMessage   `json:"data"`
+	Errors map[string]string `json:"errors"`
+}
+
+type ticketResponse struct {
+	Ticket string `json:"ticket"`
+}
+
+// connection are the resolved connection settings, the flags win over the
+// env file and the env file over the environment
+type connection struct {
+	Endpoint   string
+	Port       int
+	Username   string
+	Password   string
+	TokenName  string
+	TokenValue string
+	Insecure   bool
+	Timeout    time.Duration
+}
+
+func connectionFromContext(ctx *cli.Context) (*connection, error) {
+	c := &connection{
+		Endpoint:   defaultEndpoint,
+		Port:       ctx.Int("api-port"),
+		Username:   ctx.String("username"),
+		Password:   ctx.String("password"),
+		TokenName:  ctx.String("token-name"),
+		TokenValue: ctx.String("token-value"),
+		Insecure:   ctx.Bool("insecure"),
+		Timeout:    ctx.Duration("timeout"),
+	}
+	if repo, ok := os.LookupEnv("PBS_REPOSITORY"); ok {
+		host, user, token := parseRepository(repo)
+		c.Endpoint = host
+		if !ctx.IsSet("username") {
+			c.Username = user
+		}
+		if !ctx.IsSet("token-name") {
+			c.TokenName = token
+		}
+	}
+	if ctx.IsSet("api-endpoint") {
+		c.Endpoint = ctx.String("api-endpoint")
+	}
+	if envFile := ctx.String("env-file"); len(envFile) > 0 {
+		env, err := readEnvFile(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read env file: %w", err)
+		}
+		if repo, ok := env["PBS_REPOSITORY"]; ok {
+			host, user, token := parseRepository(repo)
+			if !ctx.IsSet("api-endpoint") {
+				c.Endpoint = host
+			}
+			if len(c.Username) == 0 {
+				c.Username = user
+			}
+			if len(c.TokenName) == 0 {
+				c.TokenName = token
+			}
+		}
+		if password, ok := env["PBS_PASSWORD"]; ok {
+			c.Password = password
+			c.TokenValue = password
+		}
+	}
+	if len(c.Endpoint) == 0 {
+		c.Endpoint = defaultEndpoint
+	}
+	if len(c.Username) == 0 {
+		return nil, errors.New("no api user specified")
+	}
+	return c, nil
+}
+
+func NewClient(c *connection) (*Client, error) {
+	client := &Client{
+		baseURL: fmt.Sprintf("https://%s:%d/api2/json", c.Endpoint, c.Port),
+		header:  make(http.Header),
+		http: &http.Client{
+			Timeout:   c.Timeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: c.Insecure}},
+		},
+	}
+	if len(c.TokenName) > 0 && len(c.TokenValue) > 0 {
+		client.header.Set("Authorization", fmt.Sprintf("PBSAPIToken=%s!%s:%s", c.Username, c.TokenName, c.TokenValue))
+		return client, nil
+	}
+	if len(c.Password) == 0 {
+		return nil, errors.New("no api password or token specified")
+	}
+	var ticket ticketResponse
+	form := url.Values{"username": {c.Username}, "password": {c.Password}}
+	if err := client.do(http.MethodPost, "/access/ticket", form, &ticket); err != nil {
+		return nil, fmt.Errorf("failed to login: %w", err)
+	}
+	client.header.Set("Cookie", "PBSAuthCookie="+ticket.Ticket)
+	return client, nil
+}
+
+func newClientFromContext(ctx *cli.Context) (*Client, error) {
+	c, err := connectionFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c)
+}
+
+func (c *Client) do(method, path string, values url.Values, out interface{}) error {
+	u := c.baseURL + path
+	var body io.Reader
+	if method == http.MethodGet {
+		if len(values) > 0 {
+			u += "?" + values.Encode()
+		}
+	} else {
+		body = strings.NewReader(values.Encode())
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	for key, value := range c.header {
+		req.Header[key] = value
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var r apiResponse
+	if resp.StatusCode != http.StatusOK {
+		if err := json.Unmarshal(data, &r); err == nil && len(r.Errors) > 0 {
+			return fmt.Errorf("%s: %s: %v", path, resp.Status, r.Errors)
+		}
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	// numbers are kept as they are, sizes do not fit into a float exactly
+	decoder := json.NewDecoder(bytes.NewReader(r.Data))
+	decoder.UseNumber()
+	return decoder.Decode(out)
+}
+
+// Get requests the path below /api2/json and decodes the data of the response
+func (c *Client) Get(path string, query url.Values, out interface{}) error {
+	return c.do(http.MethodGet, path, query, out)
+}
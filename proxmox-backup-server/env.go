@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// repositoryPattern is the [USER@REALM[!TOKEN]@]HOST format of PBS_REPOSITORY
+var repositoryPattern = regexp.MustCompile(`((?P<user>\w+@\w+)(?:!(?P<token>\w+))?@)?(?P<host>\S+)`)
+
+// parseRepository returns the host, user and token name of a PBS_REPOSITORY
+func parseRepository(repo string) (host, user, token string) {
+	match := repositoryPattern.FindStringSubmatch(repo)
+	if match == nil {
+		return "", "", ""
+	}
+	return match[repositoryPattern.SubexpIndex("host")], match[repositoryPattern.SubexpIndex("user")], match[repositoryPattern.SubexpIndex("token")]
+}
+
+// readEnvFile reads the KEY=VALUE lines of a dotenv file, values may be quoted
+// and lines may start with export
+func readEnvFile(fileName string) (map[string]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if idx := strings.Index(value, " #"); idx >= 0 {
+			value = strings.TrimSpace(value[:idx])
+		}
+		env[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	verifyNone   = "none"
+	taskNone     = "none"
+	taskOk       = "OK"
+	taskWarnings = "WARNINGS: "
+)
+
+// GroupStatus is the freshness of the snapshots of one backup group, the ages
+// are in seconds, the group is the store with the namespace
+type GroupStatus struct {
+	Group          string `json:"group"`
+	Store          string `json:"store"`
+	Ns             string `json:"ns"`
+	BackupType     string `json:"backup-type"`
+	BackupId       string `json:"backup-id"`
+	Owner          string `json:"owner"`
+	BackupCount    int    `json:"backup-count"`
+	LastBackup     int64  `json:"last-backup"`
+	LastBackupAge  int64  `json:"last-backup-age"`
+	ProtectedCount int    `json:"protected-count"`
+	VerifyState    string `json:"verify-state"`
+	VerifyTime     int64  `json:"verify-time"`
+	VerifyAge      int64  `json:"verify-age"`
+	Unverified     int    `json:"unverified"`
+	Size           int64  `json:"size"`
+	TotalSize      int64  `json:"total-size"`
+	SizeGrowth     int64  `json:"size-growth"`
+}
+
+// TaskStatus is the outcome of the last finished task of a kind, the status
+// is none if there was no such task. A task with warnings did not fail.
+type TaskStatus struct {
+	Status   string `json:"status"`
+	Failed   int    `json:"failed"`
+	Warnings int    `json:"warnings"`
+	Time     int64  `json:"time"`
+	Age      int64  `json:"age"`
+}
+
+// taskStatus returns the outcome of a finished task, the status is OK,
+// WARNINGS: COUNT or the error message
+func taskStatus(status string, start int64, now time.Time) TaskStatus {
+	t := TaskStatus{Status: status, Time: start, Age: now.Unix() - start}
+	switch {
+	case status == taskOk:
+	case strings.HasPrefix(status, taskWarnings):
+		t.Warnings, _ = strconv.Atoi(strings.TrimPrefix(status, taskWarnings))
+		if t.Warnings == 0 {
+			t.Warnings = 1
+		}
+	default:
+		t.Failed = 1
+	}
+	return t
+}
+
+// MaintenanceStatus is the last garbage collection and prune of a datastore
+type MaintenanceStatus struct {
+	Store string     `json:"store"`
+	Gc    TaskStatus `json:"gc"`
+	Prune TaskStatus `json:"prune"`
+}
+
+var freshnessCommand = cli.Command{
+	Name:   "freshness",
+	Usage:  "Age, verification and size of the newest snapshot of every backup group",
+	Action: cmdFreshness,
+}
+
+var maintenanceCommand = cli.Command{
+	Name:   "maintenance",
+	Usage:  "Outcome of the last garbage collection and prune of every datastore",
+	Action: cmdMaintenance,
+}
+
+// upidTime returns the start time of a task, the UPID looks like
+// UPID:NODE:PID:PSTART:TASKID:STARTTIME:TYPE:ID:USER: with hex numbers
+func upidTime(upid string) int64 {
+	parts := strings.Split(upid, ":")
+	if len(parts) < 6 || parts[0] != "UPID" {
+		return 0
+	}
+	t, err := strconv.ParseInt(parts[5], 16, 64)
+	if err != nil {
+		return 0
+	}
+	return t
+}
+
+// groupStatus summarizes the snapshots of one group
+func groupStatus(ns namespace, snapshots []object, now time.Time) GroupStatus {
+	sort.Slice(snapshots, func(i, j int) bool {
+		return toInt64(snapshots[i]["backup-time"]) > toInt64(snapshots[j]["backup-time"])
+	})
+	newest := snapshots[0]
+	g := GroupStatus{
+		Group:       groupName(ns.Store, ns.Ns),
+		Store:       ns.Store,
+		Ns:          ns.Ns,
+		BackupType:  fmt.Sprint(newest["backup-type"]),
+		BackupId:    fmt.Sprint(newest["backup-id"]),
+		Owner:       fmt.Sprint(newest["owner"]),
+		BackupCount: len(snapshots),
+		LastBackup:  toInt64(newest["backup-time"]),
+		VerifyState: verifyNone,
+		Size:        toInt64(newest["size"]),
+	}
+	g.LastBackupAge = now.Unix() - g.LastBackup
+	if len(snapshots) > 1 {
+		g.SizeGrowth = g.Size - toInt64(snapshots[1]["size"])
+	}
+	for _, s := range snapshots {
+		g.TotalSize += toInt64(s["size"])
+		if protected, ok := s["protected"].(bool); ok && protected {
+			g.ProtectedCount++
+		}
+		verification, ok := s["verification"].(map[string]interface{})
+		if !ok {
+			g.Unverified++
+			continue
+		}
+		// the last verification may belong to an older snapshot
+		t := upidTime(fmt.Sprint(verification["upid"]))
+		if g.VerifyState == verifyNone || t > g.VerifyTime {
+			g.VerifyState = fmt.Sprint(verification["state"])
+			g.VerifyTime = t
+		}
+	}
+	if g.VerifyTime > 0 {
+		g.VerifyAge = now.Unix() - g.VerifyTime
+	}
+	return g
+}
+
+func cmdFreshness(ctx *cli.Context) error {
+	client, err := newClientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	namespaces, err := getNamespaces(ctx, client)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	output := make([]GroupStatus, 0)
+	for _, ns := range namespaces {
+		snapshots, err := getSnapshots(client, ns)
+		if err != nil {
+			return err
+		}
+		groups := make(map[string][]object)
+		var keys []string
+		for _, s := range snapshots {
+			key := fmt.Sprintf("%s/%s", s["backup-type"], s["backup-id"])
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], s)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			output = append(output, groupStatus(ns, groups[key], now))
+		}
+	}
+	return printJson(output)
+}
+
+// taskStore returns the datastore of a task, prune jobs have the job name
+// after the store
+func taskStore(task object) string {
+	return strings.SplitN(fmt.Sprint(task["worker_id"]), ":", 2)[0]
+}
+
+// lastTasks returns the newest finished task of the given types per datastore
+func lastTasks(client *Client, now time.Time, types ...string) (map[string]TaskStatus, error) {
+	last := make(map[string]TaskStatus)
+	for _, t := range types {
+		var tasks []object
+		if err := client.Get("/nodes/localhost/tasks", url.Values{"typefilter": {t}, "limit": {"100"}}, &tasks); err != nil {
+			return nil, err
+		}
+		for _, task := range tasks {
+			// running tasks have no status yet, the outcome is the last
+			// finished one
+			status, ok := task["status"].(string)
+			if !ok {
+				continue
+			}
+			store := taskStore(task)
+			start := toInt64(task["starttime"])
+			if prev, ok := last[store]; ok && prev.Time >= start {
+				continue
+			}
+			last[store] = taskStatus(status, start, now)
+		}
+	}
+	return last, nil
+}
+
+func cmdMaintenance(ctx *cli.Context) error {
+	client, err := newClientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	usage, err := getDatastoreUsage(client)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	gc, err := lastTasks(client, now, "garbage_collection")
+	if err != nil {
+		return err
+	}
+	prune, err := lastTasks(client, now, "prune", "prunejob")
+	if err != nil {
+		return err
+	}
+	exclude := ctx.StringSlice("exclude")
+	output := make([]MaintenanceStatus, 0, len(usage))
+	for _, ds := range usage {
+		store := fmt.Sprint(ds["store"])
+		if contains(exclude, store) {
+			continue
+		}
+		m := MaintenanceStatus{Store: store, Gc: TaskStatus{Status: taskNone}, Prune: TaskStatus{Status: taskNone}}
+		if t, ok := gc[store]; ok {
+			m.Gc = t
+		}
+		if t, ok := prune[store]; ok {
+			m.Prune = t
+		}
+		output = append(output, m)
+	}
+	return printJson(output)
+}
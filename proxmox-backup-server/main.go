@@ -0,0 +1,91 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	cli.VersionFlag = &cli.BoolFlag{
+		Name:    "print-version",
+		Aliases: []string{"V"},
+		Usage:   "print only the version",
+	}
+
+	app := &cli.App{
+		Name:        "proxmox-backup-server-checker",
+		Version:     "v1.0",
+		Description: "Proxmox Backup Server checker using the HTTPS API",
+		Authors: []*cli.Author{
+			{
+				Name:  "Elbandi",
+				Email: "elso.andras@gmail.com",
+			},
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "env-file",
+				Usage: "Read PBS_REPOSITORY and PBS_PASSWORD from this file",
+			},
+			&cli.StringFlag{
+				Name:    "api-endpoint",
+				Aliases: []string{"e"},
+				Usage:   "PBS api endpoint host (default: host of PBS_REPOSITORY or " + defaultEndpoint + ")",
+			},
+			&cli.IntFlag{
+				Name:  "api-port",
+				Usage: "PBS api endpoint port",
+				Value: 8007,
+			},
+			&cli.StringFlag{
+				Name:    "username",
+				Aliases: []string{"u"},
+				Usage:   "PBS api user (root@pam, zabbix@pbs, ...)",
+			},
+			&cli.StringFlag{
+				Name:    "password",
+				Aliases: []string{"p"},
+				Usage:   "PBS api user password",
+				EnvVars: []string{"PBS_PASSWORD"},
+			},
+			&cli.StringFlag{
+				Name:  "token-name",
+				Usage: "PBS api token name",
+			},
+			&cli.StringFlag{
+				Name:    "token-value",
+				Usage:   "PBS api token value",
+				EnvVars: []string{"PBS_PASSWORD"},
+			},
+			&cli.BoolFlag{
+				Name:    "insecure",
+				Aliases: []string{"k"},
+				Usage:   "Don't verify HTTPS certificate",
+			},
+			&cli.StringSliceFlag{
+				Name:    "exclude",
+				Aliases: []string{"E"},
+				Usage:   "Exclude a datastore (STORE) or namespace (STORE/NS, STORE/_ for the root)",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "Timeout of the API requests",
+				Value: 30 * time.Second,
+			},
+		},
+		Commands: []*cli.Command{
+			&tasksCommand,
+			&datastoresCommand,
+			&groupsCommand,
+			&snapshotsCommand,
+			&freshnessCommand,
+			&maintenanceCommand,
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// the API objects are passed through, only a few fields are added or removed
+type object map[string]interface{}
+
+const timeFormat = "2006-01-02 15:04:05"
+
+var tasksCommand = cli.Command{
+	Name:   "tasks",
+	Usage:  "Last 200 tasks of the server",
+	Action: cmdTasks,
+}
+
+var datastoresCommand = cli.Command{
+	Name:   "datastores",
+	Usage:  "Usage of the datastores",
+	Action: cmdDatastores,
+}
+
+var groupsCommand = cli.Command{
+	Name:   "groups",
+	Usage:  "Backup groups of every datastore and namespace",
+	Action: cmdGroups,
+}
+
+var snapshotsCommand = cli.Command{
+	Name:   "snapshots",
+	Usage:  "Snapshots of every datastore and namespace",
+	Action: cmdSnapshots,
+}
+
+func printJson(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+// toInt64 returns an integer field, zero if it is missing
+func toInt64(value interface{}) int64 {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0
+	}
+	i, _ := n.Int64()
+	return i
+}
+
+// formatTime formats an unix timestamp field in UTC
+func formatTime(value interface{}) string {
+	if _, ok := value.(json.Number); !ok {
+		return ""
+	}
+	return time.Unix(toInt64(value), 0).UTC().Format(timeFormat)
+}
+
+// groupName is the key of the output, the root namespace is the store itself
+func groupName(store, ns string) string {
+	if len(ns) > 0 {
+		return store + "/" + ns
+	}
+	return store
+}
+
+// filterName is the name of a namespace for --exclude, the root namespace is
+// STORE/_
+func filterName(store, ns string) string {
+	if len(ns) > 0 {
+		return store + "/" + ns
+	}
+	return store + "/_"
+}
+
+func nsQuery(ns string) url.Values {
+	query := url.Values{}
+	if len(ns) > 0 {
+		query.Set("ns", ns)
+	}
+	return query
+}
+
+func getDatastoreUsage(client *Client) ([]object, error) {
+	var usage []object
+	if err := client.Get("/status/datastore-usage", nil, &usage); err != nil {
+		return nil, err
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return fmt.Sprint(usage[i]["store"]) < fmt.Sprint(usage[j]["store"])
+	})
+	return usage, nil
+}
+
+// namespace is a datastore namespace which is not excluded
+type namespace struct {
+	Store string
+	Ns    string
+}
+
+// getNamespaces returns the namespaces of the datastores, --exclude takes
+// store names and STORE/NS or STORE/_ namespace names
+func getNamespaces(ctx *cli.Context, client *Client) ([]namespace, error) {
+	usage, err := getDatastoreUsage(client)
+	if err != nil {
+		return nil, err
+	}
+	exclude := ctx.StringSlice("exclude")
+	var namespaces []namespace
+	for _, ds := range usage {
+		store := fmt.Sprint(ds["store"])
+		if contains(exclude, store) {
+			continue
+		}
+		var list []struct {
+			Ns string `json:"ns"`
+		}
+		if err := client.Get(fmt.Sprintf("/admin/datastore/%s/namespace", url.PathEscape(store)), nil, &list); err != nil {
+			return nil, err
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Ns < list[j].Ns
+		})
+		for _, ns := range list {
+			if contains(exclude, filterName(store, ns.Ns)) {
+				continue
+			}
+			namespaces = append(namespaces, namespace{Store: store, Ns: ns.Ns})
+		}
+	}
+	return namespaces, nil
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
+func cmdTasks(ctx *cli.Context) error {
+	client, err := newClientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	var tasks []object
+	if err := client.Get("/nodes/localhost/tasks", url.Values{"limit": {"200"}}, &tasks); err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		t["starttime_str"] = formatTime(t["starttime"])
+		if _, ok := t["status"]; !ok {
+			t["status"] = "RUNNING"
+		}
+	}
+	return printJson(tasks)
+}
+
+func cmdDatastores(ctx *cli.Context) error {
+	client, err := newClientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	usage, err := getDatastoreUsage(client)
+	if err != nil {
+		return err
+	}
+	for _, ds := range usage {
+		delete(ds, "history")
+	}
+	return printJson(usage)
+}
+
+// getGroups returns the backup groups with their notes
+func getGroups(client *Client, ns namespace) ([]object, error) {
+	var groups []object
+	path := fmt.Sprintf("/admin/datastore/%s", url.PathEscape(ns.Store))
+	if err := client.Get(path+"/groups", nsQuery(ns.Ns), &groups); err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		query := nsQuery(ns.Ns)
+		query.Set("backup-id", fmt.Sprint(group["backup-id"]))
+		query.Set("backup-type", fmt.Sprint(group["backup-type"]))
+		var notes string
+		if err := client.Get(path+"/group-notes", query, &notes); err != nil {
+			return nil, err
+		}
+		if len(notes) > 0 {
+			group["full-comment"] = notes
+		}
+	}
+	return groups, nil
+}
+
+func getSnapshots(client *Client, ns namespace) ([]object, error) {
+	var snapshots []object
+	if err := client.Get(fmt.Sprintf("/admin/datastore/%s/snapshots", url.PathEscape(ns.Store)), nsQuery(ns.Ns), &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// stripSnapshot removes the file list and fingerprint of the snapshot
+func stripSnapshot(s object) {
+	s["backup-time-str"] = formatTime(s["backup-time"])
+	delete(s, "fingerprint")
+	delete(s, "files")
+	if verification, ok := s["verification"].(map[string]interface{}); ok {
+		delete(verification, "upid")
+	}
+}
+
+func cmdGroups(ctx *cli.Context) error {
+	client, err := newClientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	namespaces, err := getNamespaces(ctx, client)
+	if err != nil {
+		return err
+	}
+	output := make(map[string][]object)
+	for _, ns := range namespaces {
+		groups, err := getGroups(client, ns)
+		if err != nil {
+			return err
+		}
+		output[groupName(ns.Store, ns.Ns)] = groups
+	}
+	return printJson(output)
+}
+
+func cmdSnapshots(ctx *cli.Context) error {
+	client, err := newClientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	namespaces, err := getNamespaces(ctx, client)
+	if err != nil {
+		return err
+	}
+	output := make(map[string][]object)
+	for _, ns := range namespaces {
+		snapshots, err := getSnapshots(client, ns)
+		if err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			stripSnapshot(s)
+		}
+		output[groupName(ns.Store, ns.Ns)] = snapshots
+	}
+	return printJson(output)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Client is a minimal client of the Proxmox VE API authenticated with an API
+// token, the token needs the PVEAuditor role on /
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// apiResponse is the envelope of every API response
+type apiResponse struct {
+	Data   json.RawMessage   `json:"data"`
+	Errors map[string]string `json:"errors"`
+}
+
+func NewClient(baseURL, tokenID, tokenSecret string, tlsConfig *tls.Config, timeout time.Duration) (*Client, error) {
+	if len(tokenID) == 0 || len(tokenSecret) == 0 {
+		return nil, errors.New("no api token id/secret specified")
+	}
+	if !strings.Contains(tokenID, "!") {
+		return nil, fmt.Errorf("invalid api token id %s, expected USER@REALM!TOKENID", tokenID)
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/") + "/api2/json",
+		token:   fmt.Sprintf("PVEAPIToken=%s=%s", tokenID, tokenSecret),
+		http: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// newClientFromContext creates the client from the global flags
+func newClientFromContext(ctx *cli.Context) (*Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: ctx.Bool("insecure")}
+	if caFile := ctx.String("ca-file"); len(caFile) > 0 {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+	}
+	return NewClient(ctx.String("url"), ctx.String("token-id"), ctx.String("token-secret"), tlsConfig, ctx.Duration("timeout"))
+}
+
+// Get requests the path below /api2/json and decodes the data of the response
+func (c *Client) Get(path string, query url.Values, out interface{}) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", c.token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var r apiResponse
+	if resp.StatusCode != http.StatusOK {
+		if err := json.Unmarshal(body, &r); err == nil && len(r.Errors) > 0 {
+			return fmt.Errorf("%s: %s: %v", path, resp.Status, r.Errors)
+		}
+		// the reason of authentication errors is only in the status line
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return json.Unmarshal(r.Data, out)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+// clusterEntry is an entry of cluster/status, one for the cluster and one
+// per node
+type clusterEntry struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Nodes   int    `json:"nodes"`
+	Quorate int    `json:"quorate"`
+	Version int    `json:"version"`
+	Online  int    `json:"online"`
+	Local   int    `json:"local"`
+	NodeId  int    `json:"nodeid"`
+	Ip      string `json:"ip"`
+}
+
+type ClusterNode struct {
+	Name   string `json:"name"`
+	NodeId int    `json:"nodeid"`
+	Ip     string `json:"ip"`
+	Online int    `json:"online"`
+	Local  int    `json:"local"`
+}
+
+type ClusterStatus struct {
+	Name       string        `json:"name"`
+	Standalone bool          `json:"standalone"`
+	Quorate    int           `json:"quorate"`
+	Version    int           `json:"version"`
+	Nodes      int           `json:"nodes"`
+	Online     int           `json:"online"`
+	Offline    int           `json:"offline"`
+	NodeList   []ClusterNode `json:"node_list"`
+}
+
+var clusterCommand = cli.Command{
+	Name:   "cluster",
+	Usage:  "Quorum and node membership of the cluster",
+	Action: cmdCluster,
+}
+
+func cmdCluster(ctx *cli.Context) error {
+	client, err := newClientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	var entries []clusterEntry
+	if err := client.Get("/cluster/status", nil, &entries); err != nil {
+		return err
+	}
+	// a standalone node has no cluster entry and is always quorate
+	output := ClusterStatus{Standalone: true, Quorate: 1, NodeList: make([]ClusterNode, 0)}
+	for _, e := range entries {
+		switch e.Type {
+		case "cluster":
+			output.Name = e.Name
+			output.Standalone = false
+			output.Quorate = e.Quorate
+			output.Version = e.Version
+		case "node":
+			output.NodeList = append(output.NodeList, ClusterNode{
+				Name:   e.Name,
+				NodeId: e.NodeId,
+				Ip:     e.Ip,
+				Online: e.Online,
+				Local:  e.Local,
+			})
+			if e.Online == 1 {
+				output.Online++
+			}
+		}
+	}
+	output.Nodes = len(output.NodeList)
+	output.Offline = output.Nodes - output.Online
+	return printJson(output)
+}
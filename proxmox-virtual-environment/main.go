@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	cli.VersionFlag = &cli.BoolFlag{
+		Name:    "print-version",
+		Aliases: []string{"V"},
+		Usage:   "print only the version",
+	}
+
+	app := &cli.App{
+		Name:        "proxmox-ve-checker",
+		Version:     "v1.0",
+		Description: "Proxmox Virtual Environment checker using the HTTPS API",
+		Authors: []*cli.Author{
+			{
+				Name:  "Elbandi",
+				Email: "elso.andras@gmail.com",
+			},
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "url",
+				Usage:   "Base url of the Proxmox VE API",
+				EnvVars: []string{"PVE_URL"},
+				Value:   "https://localhost:8006",
+			},
+			&cli.StringFlag{
+				Name:    "token-id",
+				Usage:   "API token id as USER@REALM!TOKENID",
+				EnvVars: []string{"PVE_TOKEN_ID"},
+			},
+			&cli.StringFlag{
+				Name:     "token-secret",
+				Usage:    "API token secret",
+				EnvVars:  []string{"PVE_TOKEN_SECRET"},
+				FilePath: "/etc/zabbix/proxmox-ve-checker.secret",
+			},
+			&cli.StringFlag{
+				Name:  "ca-file",
+				Usage: "Trusted root certificates of the API, PEM file (default: system roots)",
+			},
+			&cli.BoolFlag{
+				Name:  "insecure",
+				Usage: "Skip the verification of the API certificate",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "Timeout of the API requests",
+				Value: 10 * time.Second,
+			},
+		},
+		Commands: []*cli.Command{
+			&nodesCommand,
+			&vmsCommand,
+			&storagesCommand,
+			&clusterCommand,
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"net/url"
+	"sort"
+)
+
+const (
+	resourceNode    = "node"
+	resourceVm      = "vm"
+	resourceStorage = "storage"
+)
+
+// resource is an entry of cluster/resources, the fields depend on the type
+type resource struct {
+	Id         string  `json:"id"`
+	Type       string  `json:"type"`
+	Node       string  `json:"node"`
+	Status     string  `json:"status"`
+	VmId       int     `json:"vmid"`
+	Name       string  `json:"name"`
+	Template   int     `json:"template"`
+	HaState    string  `json:"hastate"`
+	Storage    string  `json:"storage"`
+	PluginType string  `json:"plugintype"`
+	Shared     int     `json:"shared"`
+	Content    string  `json:"content"`
+	Cpu        float64 `json:"cpu"`
+	MaxCpu     float64 `json:"maxcpu"`
+	Mem        int64   `json:"mem"`
+	MaxMem     int64   `json:"maxmem"`
+	Disk       int64   `json:"disk"`
+	MaxDisk    int64   `json:"maxdisk"`
+	Uptime     int64   `json:"uptime"`
+	NetIn      int64   `json:"netin"`
+	NetOut     int64   `json:"netout"`
+	DiskRead   int64   `json:"diskread"`
+	DiskWrite  int64   `json:"diskwrite"`
+}
+
+type NodeStatus struct {
+	Id      string  `json:"id"`
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Cpu     float64 `json:"cpu"`
+	MaxCpu  float64 `json:"maxcpu"`
+	Mem     int64   `json:"mem"`
+	MaxMem  int64   `json:"maxmem"`
+	Disk    int64   `json:"disk"`
+	MaxDisk int64   `json:"maxdisk"`
+	Uptime  int64   `json:"uptime"`
+}
+
+// VmStatus is a qemu virtual machine or a lxc container
+type VmStatus struct {
+	Id        string  `json:"id"`
+	VmId      int     `json:"vmid"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Node      string  `json:"node"`
+	Status    string  `json:"status"`
+	Template  int     `json:"template"`
+	HaState   string  `json:"hastate"`
+	Cpu       float64 `json:"cpu"`
+	MaxCpu    float64 `json:"maxcpu"`
+	Mem       int64   `json:"mem"`
+	MaxMem    int64   `json:"maxmem"`
+	Disk      int64   `json:"disk"`
+	MaxDisk   int64   `json:"maxdisk"`
+	Uptime    int64   `json:"uptime"`
+	NetIn     int64   `json:"netin"`
+	NetOut    int64   `json:"netout"`
+	DiskRead  int64   `json:"diskread"`
+	DiskWrite int64   `json:"diskwrite"`
+}
+
+type StorageStatus struct {
+	Id         string `json:"id"`
+	Storage    string `json:"storage"`
+	Node       string `json:"node"`
+	Status     string `json:"status"`
+	PluginType string `json:"plugintype"`
+	Shared     int    `json:"shared"`
+	Content    string `json:"content"`
+	Disk       int64  `json:"disk"`
+	MaxDisk    int64  `json:"maxdisk"`
+}
+
+var nodesCommand = cli.Command{
+	Name:   "nodes",
+	Usage:  "Status of the cluster nodes",
+	Action: cmdNodes,
+}
+
+var vmsCommand = cli.Command{
+	Name:   "vms",
+	Usage:  "Status of the virtual machines and containers",
+	Action: cmdVms,
+}
+
+var storagesCommand = cli.Command{
+	Name:   "storages",
+	Usage:  "Status of the storages of every node",
+	Action: cmdStorages,
+}
+
+func getResources(ctx *cli.Context, resourceType string) ([]resource, error) {
+	client, err := newClientFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var resources []resource
+	if err := client.Get("/cluster/resources", url.Values{"type": {resourceType}}, &resources); err != nil {
+		return nil, err
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Id < resources[j].Id
+	})
+	return resources, nil
+}
+
+func printJson(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func cmdNodes(ctx *cli.Context) error {
+	resources, err := getResources(ctx, resourceNode)
+	if err != nil {
+		return err
+	}
+	output := make([]NodeStatus, 0, len(resources))
+	for _, r := range resources {
+		output = append(output, NodeStatus{
+			Id:      r.Id,
+			Name:    r.Node,
+			Status:  r.Status,
+			Cpu:     r.Cpu,
+			MaxCpu:  r.MaxCpu,
+			Mem:     r.Mem,
+			MaxMem:  r.MaxMem,
+			Disk:    r.Disk,
+			MaxDisk: r.MaxDisk,
+			Uptime:  r.Uptime,
+		})
+	}
+	return printJson(output)
+}
+
+func cmdVms(ctx *cli.Context) error {
+	resources, err := getResources(ctx, resourceVm)
+	if err != nil {
+		return err
+	}
+	output := make([]VmStatus, 0, len(resources))
+	for _, r := range resources {
+		haState := r.HaState
+		if len(haState) == 0 {
+			haState = "unmanaged"
+		}
+		output = append(output, VmStatus{
+			Id:        r.Id,
+			VmId:      r.VmId,
+			Name:      r.Name,
+			Type:      r.Type,
+			Node:      r.Node,
+			Status:    r.Status,
+			Template:  r.Template,
+			HaState:   haState,
+			Cpu:       r.Cpu,
+			MaxCpu:    r.MaxCpu,
+			Mem:       r.Mem,
+			MaxMem:    r.MaxMem,
+			Disk:      r.Disk,
+			MaxDisk:   r.MaxDisk,
+			Uptime:    r.Uptime,
+			NetIn:     r.NetIn,
+			NetOut:    r.NetOut,
+			DiskRead:  r.DiskRead,
+			DiskWrite: r.DiskWrite,
+		})
+	}
+	return printJson(output)
+}
+
+func cmdStorages(ctx *cli.Context) error {
+	resources, err := getResources(ctx, resourceStorage)
+	if err != nil {
+		return err
+	}
+	output := make([]StorageStatus, 0, len(resources))
+	for _, r := range resources {
+		output = append(output, StorageStatus{
+			Id:         r.Id,
+			Storage:    r.Storage,
+			Node:       r.Node,
+			Status:     r.Status,
+			PluginType: r.PluginType,
+			Shared:     r.Shared,
+			Content:    r.Content,
+			Disk:       r.Disk,
+			MaxDisk:    r.MaxDisk,
+		})
+	}
+	return printJson(output)
+}
@@ -5,6 +5,7 @@ go 1.14
 replace github.com/btcsuite/btcd v0.22.3 => github.com/Elbandi/btcd v0.0.0-20250113200320-3b6f47a226ff
 
 require (
+	github.com/Elbandi/zabbix-checker v0.0.0-20241219185337-e1f809219cb7
 	github.com/btcsuite/btcd v0.22.3
 	github.com/stretchr/testify v1.7.0 // indirect
 	golang.org/x/crypto v0.32.0 // indirect
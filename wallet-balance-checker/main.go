@@ -4,12 +4,13 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/Elbandi/zabbix-checker/common/lld"
 	"github.com/btcsuite/btcd/rpcclient"
 	"gopkg.in/ini.v1"
 	"log"
 	"os"
+	"sort"
 	"strconv"
-	"strings"
 )
 
 type BitcoinConfig struct {
@@ -27,7 +28,54 @@ func FatalErr(err error, str string) {
 
 type balanceData map[string]interface{}
 
+type groupBalance struct {
+	Balance     float64
+	Unconfirmed float64
+	Immature    float64
+}
+
+// walletOutput is the common part of listunspent and listtransactions entries
+type walletOutput struct {
+	Address  string  `json:"address"`
+	Label    string  `json:"label"`
+	Account  string  `json:"account"`
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+}
+
+func (o walletOutput) label(labels map[string]string) string {
+	if len(o.Label) > 0 {
+		return o.Label
+	}
+	if len(o.Account) > 0 {
+		return o.Account
+	}
+	return labels[o.Address]
+}
+
+func rawRequest(client *rpcclient.Client, method string, result interface{}, params ...interface{}) error {
+	rawParams := make([]json.RawMessage, 0, len(params))
+	for _, p := range params {
+		raw, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		rawParams = append(rawParams, raw)
+	}
+	res, err := client.RawRequest(method, rawParams)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(res, result)
+}
+
 func main() {
+	var rulesFile string
+	var discovery bool
+	var transactionCount int
+	flag.StringVar(&rulesFile, "rules", "", "balance grouping rules file")
+	flag.BoolVar(&discovery, "discovery", false, "output low level discovery of the groups")
+	flag.IntVar(&transactionCount, "transactions", 1000, "number of transactions searched for immature balances")
 	flag.Parse()
 	log.SetOutput(os.Stderr)
 
@@ -43,6 +91,12 @@ func main() {
 	if fi.Size() == 0 {
 		log.Fatal("empty file")
 	}
+	var rules groupRules
+	if len(rulesFile) > 0 {
+		rules, err = loadRules(rulesFile)
+		FatalErr(err, "Failed to load rules file")
+	}
+
 	config := &BitcoinConfig{Hostname: "127.0.0.1", Port: 8232}
 	err = ini.MapTo(config, configPath)
 	FatalErr(err, "Failed to load config file")
@@ -62,21 +116,109 @@ func main() {
 	addresses, err := client.ListAddressGroupings()
 	FatalErr(err, "Failed to get addresses")
 
-	balances := make(map[string]float64)
+	balances := make(map[string]*groupBalance)
+	for _, rule := range rules {
+		balances[rule.Name] = &groupBalance{}
+	}
+	get := func(address, label string) *groupBalance {
+		if len(rules) == 0 && len(label) == 0 {
+			return nil
+		}
+		name := rules.group(address, label)
+		if len(name) == 0 {
+			return nil
+		}
+		if _, ok := balances[name]; !ok {
+			balances[name] = &groupBalance{}
+		}
+		return balances[name]
+	}
+	labels := make(map[string]string)
 	for _, a := range addresses {
-		if len(a.Account) == 0 || a.Amount == 0 {
+		labels[a.Address] = a.Account
+		if a.Amount == 0 {
+			continue
+		}
+		if b := get(a.Address, a.Account); b != nil {
+			b.Balance += a.Amount
+		}
+	}
+
+	// the unconfirmed and immature balances are left out if the wallet
+	// does not answer these calls
+	var unspents []walletOutput
+	hasUnconfirmed := true
+	if err = rawRequest(client, "listunspent", &unspents, 0, 0); err != nil {
+		log.Printf("Failed to get unconfirmed outputs: %s", err.Error())
+		hasUnconfirmed = false
+	}
+	for _, u := range unspents {
+		if b := get(u.Address, u.label(labels)); b != nil {
+			b.Unconfirmed += u.Amount
+		}
+	}
+
+	var transactions []walletOutput
+	hasImmature := true
+	if err = rawRequest(client, "listtransactions", &transactions, "*", transactionCount); err != nil {
+		log.Printf("Failed to get transactions: %s", err.Error())
+		hasImmature = false
+	}
+	for _, t := range transactions {
+		if t.Category != "immature" {
 			continue
 		}
-		account := a.Account
-		if idx := strings.Index(account, "-"); idx != -1 {
-			// truncate account name at "-"
-			account = account[:idx]
+		if b := get(t.Address, t.label(labels)); b != nil {
+			b.Immature += t.Amount
+		}
+	}
+
+	names := make([]string, 0, len(balances))
+	for name := range balances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if discovery {
+		d := make(lld.DiscoveryData, 0)
+		for _, name := range names {
+			item := make(lld.DiscoveryItem, 0)
+			item["GROUP"] = name
+			if rule := rules.find(name); rule != nil {
+				if rule.Min != nil {
+					item["MIN_BALANCE"] = strconv.FormatFloat(*rule.Min, 'f', -1, 64)
+				}
+				if rule.Max != nil {
+					item["MAX_BALANCE"] = strconv.FormatFloat(*rule.Max, 'f', -1, 64)
+				}
+			}
+			d = append(d, item)
 		}
-		balances[account] += a.Amount
+		fmt.Print(d.Json())
+		return
 	}
+
 	result := make([]balanceData, 0)
-	for name, balance := range balances {
-		result = append(result, balanceData{"name": name, "balance": balance})
+	for _, name := range names {
+		b := balances[name]
+		data := balanceData{"name": name, "balance": b.Balance}
+		if hasUnconfirmed {
+			data["unconfirmed"] = b.Unconfirmed
+		}
+		if hasImmature {
+			data["immature"] = b.Immature
+		}
+		if rule := rules.find(name); rule != nil {
+			if rule.Min != nil {
+				data["min"] = *rule.Min
+				data["low"] = b.Balance < *rule.Min
+			}
+			if rule.Max != nil {
+				data["max"] = *rule.Max
+				data["high"] = b.Balance > *rule.Max
+			}
+		}
+		result = append(result, data)
 	}
 	d, err := json.Marshal(result)
 	FatalErr(err, "Failed to marshal balances")
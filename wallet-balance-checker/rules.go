@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/ini.v1"
+	"regexp"
+	"strings"
+)
+
+// groupRule selects the addresses of a balance group by label regex or by
+// an explicit address list, the first matching rule wins
+type groupRule struct {
+	Name      string
+	Label     *regexp.Regexp
+	Addresses []string
+	Min       *float64
+	Max       *float64
+}
+
+type groupRules []groupRule
+
+// loadRules reads the grouping rules, every ini section is a group:
+//
+//	[hot]
+//	label = ^hot-
+//	addresses = addr1, addr2
+//	min = 0.5
+//	max = 10
+func loadRules(filename string) (groupRules, error) {
+	cfg, err := ini.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	rules := make(groupRules, 0)
+	for _, section := range cfg.Sections() {
+		if section.Name() == ini.DefaultSection {
+			continue
+		}
+		rule := groupRule{Name: section.Name()}
+		if section.HasKey("label") {
+			rule.Label, err = regexp.Compile(section.Key("label").String())
+			if err != nil {
+				return nil, fmt.Errorf("group %s: invalid label: %w", rule.Name, err)
+			}
+		}
+		if section.HasKey("addresses") {
+			rule.Addresses = section.Key("addresses").Strings(",")
+		}
+		if rule.Label == nil && len(rule.Addresses) == 0 {
+			return nil, fmt.Errorf("group %s: label or addresses required", rule.Name)
+		}
+		for _, limit := range []struct {
+			key   string
+			value **float64
+		}{{"min", &rule.Min}, {"max", &rule.Max}} {
+			if !section.HasKey(limit.key) {
+				continue
+			}
+			v, err := section.Key(limit.key).Float64()
+			if err != nil {
+				return nil, fmt.Errorf("group %s: invalid %s: %w", rule.Name, limit.key, err)
+			}
+			*limit.value = &v
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// group returns the group name of an address, empty string if none matches.
+// Without rules the label is truncated at the first "-".
+func (r groupRules) group(address, label string) string {
+	if len(r) == 0 {
+		if idx := strings.Index(label, "-"); idx != -1 {
+			// truncate account name at "-"
+			return label[:idx]
+		}
+		return label
+	}
+	for _, rule := range r {
+		for _, a := range rule.Addresses {
+			if a == address {
+				return rule.Name
+			}
+		}
+		if rule.Label != nil && len(label) > 0 && rule.Label.MatchString(label) {
+			return rule.Name
+		}
+	}
+	return ""
+}
+
+func (r groupRules) find(name string) *groupRule {
+	for i := range r {
+		if r[i].Name == name {
+			return &r[i]
+		}
+	}
+	return nil
+}
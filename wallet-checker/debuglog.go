@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// first run reads only the tail of the log for the last UpdateTip
+const firstReadSize = 1024 * 1024
+
+var (
+	logClasses = []struct {
+		name    string
+		pattern *regexp.Regexp
+	}{
+		{"errors", regexp.MustCompile(`ERROR:`)},
+		{"reorgs", regexp.MustCompile(`(?i)reorganiz`)},
+		{"misbehaving", regexp.MustCompile(`Misbehaving`)},
+		{"diskspace", regexp.MustCompile(`(?i)disk space`)},
+		{"corruption", regexp.MustCompile(`Corrupted block database`)},
+	}
+	updateTipPattern = regexp.MustCompile(`^(\d{4}-\d\d-\d\d[ T]\d\d:\d\d:\d\d(?:\.\d+)?Z?)?.*?UpdateTip: .*\bheight=(\d+)\b.*\bdate='([^']+)'`)
+	logDateFormats   = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+)
+
+// logState is kept between runs to continue the log where the last run stopped
+type logState struct {
+	Inode      uint64 `json:"inode"`
+	Offset     int64  `json:"offset"`
+	TipHeight  int64  `json:"tip_height"`
+	TipTime    int64  `json:"tip_time"`
+	TipLogTime int64  `json:"tip_log_time"`
+}
+
+func parseLogDate(value string) int64 {
+	for _, format := range logDateFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t.Unix()
+		}
+	}
+	return 0
+}
+
+func loadLogState(filename string) logState {
+	var state logState
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return logState{Offset: -1}
+	}
+	if err = json.Unmarshal(content, &state); err != nil {
+		return logState{Offset: -1}
+	}
+	return state
+}
+
+func saveLogState(filename string, state logState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err = ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// findRotated returns the rotated copy of the log with the given inode, like
+// debug.log.1 of logrotate, nil if there is none
+func findRotated(logPath string, inode uint64) *os.File {
+	if inode == 0 {
+		return nil
+	}
+	matches, _ := filepath.Glob(logPath + ".*")
+	for _, match := range matches {
+		fi, err := os.Stat(match)
+		if err != nil || !fi.Mode().IsRegular() || fileInode(fi) != inode {
+			continue
+		}
+		if file, err := os.Open(match); err == nil {
+			return file
+		}
+	}
+	return nil
+}
+
+// readLog reads the lines of file from offset and returns the offset after
+// the last complete line, the lines are counted into counts if it is not nil,
+// the incomplete last line is read too if the file is final
+func readLog(file *os.File, offset int64, skipPartial, final bool, state *logState, counts map[string]int) (int64, error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && (!final || len(line) == 0) {
+			// keep an incomplete last line for the next run
+			break
+		}
+		offset += int64(len(line))
+		if skipPartial {
+			skipPartial = false
+			continue
+		}
+		if counts != nil {
+			for _, class := range logClasses {
+				if class.pattern.Match(line) {
+					counts[class.name]++
+				}
+			}
+		}
+		if m := updateTipPattern.FindSubmatch(bytes.TrimSpace(line)); m != nil {
+			state.TipHeight, _ = strconv.ParseInt(string(m[2]), 10, 64)
+			state.TipTime = parseLogDate(string(m[3]))
+			state.TipLogTime = parseLogDate(string(m[1]))
+		}
+		if err != nil {
+			break
+		}
+	}
+	return offset, nil
+}
+
+// analyzeLog reads the new lines of debug.log since the last run and returns
+// the error class counts of these lines and the last UpdateTip
+func analyzeLog(logPath, name, stateDir string) ([]Item, error) {
+	stateFile := filepath.Join(stateDir, "wallet-checker-"+name+".json")
+	state := loadLogState(stateFile)
+
+	file, err := os.Open(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	inode := fileInode(fi)
+	counts := make(map[string]int)
+	switch {
+	case state.Offset < 0:
+		// no state yet, the old lines are not new events, the tail is read
+		// only for the last UpdateTip and the first line of it is likely partial
+		offset := fi.Size() - firstReadSize
+		if offset < 0 {
+			offset = 0
+		}
+		if state.Offset, err = readLog(file, offset, offset > 0, false, &state, nil); err != nil {
+			return nil, err
+		}
+	case state.Inode != inode:
+		// log rotated, the rest of the old file is read before the new one
+		if rotated := findRotated(logPath, state.Inode); rotated != nil {
+			_, err = readLog(rotated, state.Offset, false, true, &state, counts)
+			rotated.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		state.Offset = 0
+	case fi.Size() < state.Offset:
+		// log truncated
+		state.Offset = 0
+	}
+	state.Inode = inode
+
+	if state.Offset, err = readLog(file, state.Offset, false, false, &state, counts); err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0)
+	for _, class := range logClasses {
+		items = append(items, Item{"wallet.log." + class.name + "[" + name + "]", strconv.Itoa(counts[class.name])})
+	}
+	if state.TipHeight > 0 {
+		items = append(items,
+			Item{"wallet.log.tipheight[" + name + "]", strconv.FormatInt(state.TipHeight, 10)},
+			Item{"wallet.log.tiptime[" + name + "]", strconv.FormatInt(state.TipTime, 10)},
+		)
+		if state.TipLogTime > 0 {
+			items = append(items, Item{"wallet.log.tiplogtime[" + name + "]", strconv.FormatInt(state.TipLogTime, 10)})
+		}
+	}
+	return items, saveLogState(stateFile, state)
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "os"
+
+// no inode on windows, rotation is detected by the file size only
+func fileInode(_ os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDriver = "bitcoin"
+	driverFile    = ".driver"
+)
+
+var ErrUnknownDriver = errors.New("unknown wallet driver")
+
+// Item is one zabbix sender value, the key is completed with the wallet name
+type Item struct {
+	Key   string
+	Value string
+}
+
+// Driver collects the items of one wallet daemon. Collect returns the items
+// queried before an error too, so partial data is still sent.
+type Driver interface {
+	DefaultPort() int
+	Collect(name string, config *BitcoinConfig, timeout time.Duration) ([]Item, error)
+}
+
+var drivers = map[string]Driver{
+	"bitcoin": &bitcoinDriver{port: 8332},
+	"zcash":   &zcashDriver{bitcoinDriver{port: 8232}},
+	"pos":     &posDriver{bitcoinDriver{port: 8332}},
+}
+
+// driverName returns the driver selected by the sidecar .driver file or by
+// the zabbixdriver key of the wallet config
+func driverName(path string, config *BitcoinConfig) (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(path, driverFile))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if name := strings.TrimSpace(string(content)); len(name) > 0 {
+		return name, nil
+	}
+	if len(config.Driver) > 0 {
+		return config.Driver, nil
+	}
+	return defaultDriver, nil
+}
+
+func getDriver(name string) (Driver, error) {
+	driver, ok := drivers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, name)
+	}
+	return driver, nil
+}
+
+func rawRequest(client *walletClient, method string, result interface{}) error {
+	return client.Call(method, result)
+}
+
+// bitcoinDriver handles the Bitcoin Core clones
+type bitcoinDriver struct {
+	port int
+}
+
+func (d *bitcoinDriver) DefaultPort() int {
+	return d.port
+}
+
+func (d *bitcoinDriver) collect(client *walletClient, name string) ([]Item, error) {
+	items := make([]Item, 0)
+	// Get the current block count.
+	blockCount, err := client.GetBlockCount()
+	if err != nil {
+		return items, err
+	}
+	items = append(items, Item{"wallet.blocks[" + name + "]", strconv.FormatInt(blockCount, 10)})
+	blockhash, err := client.GetBlockHash(blockCount)
+	if err != nil {
+		return items, err
+	}
+	blockTime, err := client.GetBlockTime(blockhash)
+	if err != nil {
+		return items, err
+	}
+	items = append(items, Item{"wallet.blocktime[" + name + "]", strconv.FormatInt(blockTime, 10)})
+	balance, err := client.GetBalance()
+	if err != nil {
+		return items, err
+	}
+	items = append(items, Item{"wallet.balance[" + name + "]", fmt.Sprintf("%f", balance)})
+	return items, nil
+}
+
+func (d *bitcoinDriver) Collect(name string, config *BitcoinConfig, timeout time.Duration) ([]Item, error) {
+	client := newRpcClient(config, timeout)
+	defer client.Close()
+
+	return d.collect(client, name)
+}
+
+// zcashDriver adds the shielded balances to the bitcoin items
+type zcashDriver struct {
+	bitcoinDriver
+}
+
+type zcashTotalBalance struct {
+	Transparent float64 `json:"transparent,string"`
+	Private     float64 `json:"private,string"`
+	Total       float64 `json:"total,string"`
+}
+
+func (d *zcashDriver) Collect(name string, config *BitcoinConfig, timeout time.Duration) ([]Item, error) {
+	client := newRpcClient(config, timeout)
+	defer client.Close()
+
+	items, err := d.collect(client, name)
+	if err != nil {
+		return items, err
+	}
+	var balance zcashTotalBalance
+	if err = rawRequest(client, "z_gettotalbalance", &balance); err != nil {
+		return items, err
+	}
+	items = append(items,
+		Item{"wallet.balance.transparent[" + name + "]", fmt.Sprintf("%f", balance.Transparent)},
+		Item{"wallet.balance.private[" + name + "]", fmt.Sprintf("%f", balance.Private)},
+		Item{"wallet.balance.total[" + name + "]", fmt.Sprintf("%f", balance.Total)},
+	)
+	return items, nil
+}
+
+// posDriver adds the staking state of proof-of-stake coins to the bitcoin items
+type posDriver struct {
+	bitcoinDriver
+}
+
+type stakingInfo struct {
+	Enabled        bool    `json:"enabled"`
+	Staking        bool    `json:"staking"`
+	Weight         float64 `json:"weight"`
+	NetStakeWeight float64 `json:"netstakeweight"`
+	ExpectedTime   int64   `json:"expectedtime"`
+}
+
+func boolValue(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func (d *posDriver) Collect(name string, config *BitcoinConfig, timeout time.Duration) ([]Item, error) {
+	client := newRpcClient(config, timeout)
+	defer client.Close()
+
+	items, err := d.collect(client, name)
+	if err != nil {
+		return items, err
+	}
+	var info stakingInfo
+	if err = rawRequest(client, "getstakinginfo", &info); err != nil {
+		return items, err
+	}
+	items = append(items,
+		Item{"wallet.staking.enabled[" + name + "]", boolValue(info.Enabled)},
+		Item{"wallet.staking.active[" + name + "]", boolValue(info.Staking)},
+		Item{"wallet.staking.weight[" + name + "]", fmt.Sprintf("%f", info.Weight)},
+		Item{"wallet.staking.netweight[" + name + "]", fmt.Sprintf("%f", info.NetStakeWeight)},
+		Item{"wallet.staking.expectedtime[" + name + "]", strconv.FormatInt(info.ExpectedTime, 10)},
+	)
+	return items, nil
+}
@@ -2,11 +2,10 @@ module wallet-checker
 
 go 1.14
 
-replace github.com/btcsuite/btcd v0.22.3 => github.com/Elbandi/btcd v0.0.0-20250113200320-3b6f47a226ff
-
 require (
-	github.com/Elbandi/zabbix-checker v0.0.0-20241219185337-e1f809219cb7
-	github.com/btcsuite/btcd v0.22.3
+	github.com/Elbandi/zabbix-checker/common v0.0.0-00010101000000-000000000000
 	github.com/stretchr/testify v1.10.0 // indirect
 	gopkg.in/ini.v1 v1.67.0
 )
+
+replace github.com/Elbandi/zabbix-checker/common => ../common
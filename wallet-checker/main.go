@@ -4,14 +4,13 @@ import (
 	"flag"
 	"fmt"
 	"github.com/Elbandi/zabbix-checker/common/lld"
-	"github.com/btcsuite/btcd/rpcclient"
-	"gopkg.in/ini.v1"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type arrayFlags []string
@@ -39,6 +38,7 @@ type BitcoinConfig struct {
 	Port     int    `ini:"rpcport"`
 	Username string `ini:"rpcuser"`
 	Password string `ini:"rpcpassword"`
+	Driver   string `ini:"zabbixdriver"`
 }
 
 func main() {
@@ -81,6 +81,28 @@ func main() {
 	flag.Var(&excludeSearchFlag, "exclude", excludeSearchDescription)
 	flag.Var(&excludeSearchFlag, "e", excludeSearchDescription)
 
+	var concurrencyFlag int
+	const (
+		concurrencyDefault     = 4
+		concurrencyDescription = "number of wallets polled at the same time"
+	)
+	flag.IntVar(&concurrencyFlag, "concurrency", concurrencyDefault, concurrencyDescription)
+	flag.IntVar(&concurrencyFlag, "c", concurrencyDefault, concurrencyDescription)
+
+	var timeoutFlag time.Duration
+	const (
+		timeoutDefault     = 10 * time.Second
+		timeoutDescription = "deadline of one rpc call"
+	)
+	flag.DurationVar(&timeoutFlag, "timeout", timeoutDefault, timeoutDescription)
+	flag.DurationVar(&timeoutFlag, "t", timeoutDefault, timeoutDescription)
+
+	var stateDirFlag string
+	const (
+		stateDirDescription = "directory of the debug.log read offsets"
+	)
+	flag.StringVar(&stateDirFlag, "statedir", os.TempDir(), stateDirDescription)
+
 	flag.Parse()
 	log.SetOutput(os.Stderr)
 
@@ -120,67 +142,9 @@ func main() {
 		os.Exit(1)
 	}
 	fmt.Printf("\"%s\" \"wallet.discovery\" %s\n", hostnameFlag, strconv.Quote(discovery.JsonLine()))
-	for _, element := range discovery {
-		logPath := filepath.Join(element["PATH"], "debug.log")
-		fi, err := os.Stat(logPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				fmt.Printf("\"%s\" \"vfs.file.size[%s]\" \"0\"\n", hostnameFlag, logPath)
-			} else {
-				log.Print(err)
-			}
-		} else {
-			fmt.Printf("\"%s\" \"vfs.file.size[%s]\" \"%d\"\n", hostnameFlag, logPath, fi.Size())
+	for _, items := range pollWallets(discovery, concurrencyFlag, timeoutFlag, stateDirFlag) {
+		for _, item := range items {
+			fmt.Printf("\"%s\" \"%s\" \"%s\"\n", hostnameFlag, item.Key, item.Value)
 		}
-
-		config := &BitcoinConfig{Hostname: "127.0.0.1", Port: 8332}
-		err = ini.MapTo(config, filepath.Join(element["PATH"], element["NAME"]+".conf"))
-		if err != nil {
-			log.Print(err)
-			continue
-		}
-		connCfg := &rpcclient.ConnConfig{
-			Host:         config.Hostname + ":" + strconv.Itoa(config.Port),
-			User:         config.Username,
-			Pass:         config.Password,
-			HTTPPostMode: true, // Bitcoin core only supports HTTP POST mode
-			DisableTLS:   true, // Bitcoin core does not provide TLS by default
-		}
-
-		func() {
-			// Notice the notification parameter is nil since notifications are
-			// not supported in HTTP POST mode.
-			client, err := rpcclient.New(connCfg, nil)
-			if err != nil {
-				log.Print(err)
-				return
-			}
-			defer client.Shutdown()
-
-			// Get the current block count.
-			blockCount, err := client.GetBlockCount()
-			if err != nil {
-				log.Print(err)
-				return
-			}
-			fmt.Printf("\"%s\" \"wallet.blocks[%s]\" \"%d\"\n", hostnameFlag, element["NAME"], blockCount)
-			blockhash, err := client.GetBlockHash(blockCount)
-			if err != nil {
-				log.Print(err)
-				return
-			}
-			block, err := client.GetBlockVerbose(blockhash)
-			if err != nil {
-				log.Print(err)
-				return
-			}
-			fmt.Printf("\"%s\" \"wallet.blocktime[%s]\" \"%d\"\n", hostnameFlag, element["NAME"], block.Time)
-			balance, err := client.GetBalance()
-			if err != nil {
-				log.Print(err)
-				return
-			}
-			fmt.Printf("\"%s\" \"wallet.balance[%s]\" \"%f\"\n", hostnameFlag, element["NAME"], balance.ToBTC())
-		}()
 	}
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Elbandi/zabbix-checker/common/jsonrpc"
+	"github.com/Elbandi/zabbix-checker/common/lld"
+	"gopkg.in/ini.v1"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	statusOk          = "ok"
+	statusTimeout     = "timeout"
+	statusAuthError   = "auth_error"
+	statusConnRefused = "conn_refused"
+	statusError       = "error"
+)
+
+func walletStatus(err error) string {
+	switch {
+	case err == nil:
+		return statusOk
+	case errors.Is(err, jsonrpc.ErrTimeout):
+		return statusTimeout
+	case errors.Is(err, syscall.ECONNREFUSED), strings.Contains(err.Error(), "connection refused"):
+		return statusConnRefused
+	case errors.Is(err, jsonrpc.ErrAuth):
+		return statusAuthError
+	default:
+		return statusError
+	}
+}
+
+// pollWallet collects every item of one discovered wallet
+func pollWallet(path, name string, timeout time.Duration, stateDir string) []Item {
+	items := make([]Item, 0)
+	logPath := filepath.Join(path, "debug.log")
+	fi, err := os.Stat(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			items = append(items, Item{"vfs.file.size[" + logPath + "]", "0"})
+		} else {
+			log.Print(err)
+		}
+	} else {
+		items = append(items, Item{"vfs.file.size[" + logPath + "]", fmt.Sprintf("%d", fi.Size())})
+		logItems, err := analyzeLog(logPath, name, stateDir)
+		if err != nil {
+			log.Printf("%s: %s", name, err)
+		}
+		items = append(items, logItems...)
+	}
+
+	status := func(err error) []Item {
+		if err != nil {
+			log.Printf("%s: %s", name, err)
+		}
+		return append(items, Item{"wallet.status[" + name + "]", walletStatus(err)})
+	}
+	config := &BitcoinConfig{Hostname: "127.0.0.1"}
+	err = ini.MapTo(config, filepath.Join(path, name+".conf"))
+	if err != nil {
+		return status(err)
+	}
+	selected, err := driverName(path, config)
+	if err != nil {
+		return status(err)
+	}
+	driver, err := getDriver(selected)
+	if err != nil {
+		return status(err)
+	}
+	if config.Port == 0 {
+		config.Port = driver.DefaultPort()
+	}
+
+	collected, err := driver.Collect(name, config, timeout)
+	items = append(items, collected...)
+	return status(err)
+}
+
+// pollWallets polls the wallets with at most concurrency daemons queried at
+// the same time, the result keeps the discovery order
+func pollWallets(wallets lld.DiscoveryData, concurrency int, timeout time.Duration, stateDir string) [][]Item {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	result := make([][]Item, len(wallets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, element := range wallets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, path, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result[i] = pollWallet(path, name, timeout, stateDir)
+		}(i, element["PATH"], element["NAME"])
+	}
+	wg.Wait()
+	return result
+}
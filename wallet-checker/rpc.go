@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/Elbandi/zabbix-checker/common/jsonrpc"
+	"time"
+)
+
+// walletClient adds the calls of the bitcoin items to the JSON-RPC client
+type walletClient struct {
+	*jsonrpc.Client
+}
+
+func newRpcClient(config *BitcoinConfig, timeout time.Duration) *walletClient {
+	return &walletClient{jsonrpc.NewClient(config.Hostname, config.Port, config.Username, config.Password, timeout)}
+}
+
+func (c *walletClient) GetBlockCount() (int64, error) {
+	var count int64
+	err := c.Call("getblockcount", &count)
+	return count, err
+}
+
+func (c *walletClient) GetBlockHash(height int64) (string, error) {
+	var hash string
+	err := c.Call("getblockhash", &hash, height)
+	return hash, err
+}
+
+// GetBlockTime returns the time of a block
+func (c *walletClient) GetBlockTime(hash string) (int64, error) {
+	var block struct {
+		Time int64 `json:"time"`
+	}
+	err := c.Call("getblock", &block, hash)
+	return block.Time, err
+}
+
+func (c *walletClient) GetBalance() (float64, error) {
+	var balance float64
+	err := c.Call("getbalance", &balance)
+	return balance, err
+}
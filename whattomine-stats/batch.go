@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Elbandi/zabbix-checker/common/lld"
+)
+
+// CoinStatus is a coin of the coins action
+type CoinStatus struct {
+	Id                 uint64  `json:"id"`
+	Name               string  `json:"name"`
+	Tag                string  `json:"tag"`
+	Algorithm          string  `json:"algorithm"`
+	BlockTime          float64 `json:"block_time"`
+	BlockReward        float64 `json:"block_reward"`
+	Difficulty         float64 `json:"difficulty"`
+	NetHash            float64 `json:"nethash"`
+	ExchangeRate       float64 `json:"exchange_rate"`
+	ExchangeRate24     float64 `json:"exchange_rate24"`
+	EstimatedRewards   float64 `json:"estimated_rewards"`
+	EstimatedRewards24 float64 `json:"estimated_rewards24"`
+	BtcRevenue         float64 `json:"btc_revenue"`
+	BtcRevenue24       float64 `json:"btc_revenue24"`
+	Profitability      uint64  `json:"profitability"`
+	Profitability24    uint64  `json:"profitability24"`
+	Lagging            int     `json:"lagging"`
+	Timestamp          int64   `json:"timestamp"`
+}
+
+// selectCoins returns the coins which match a filter by id, tag or algorithm
+// sorted by id, every coin if there is no filter
+func selectCoins(coins Coins, filters []string) []Coin {
+	var selected []Coin
+	for name, coin := range coins {
+		coin.Name = name
+		if len(filters) == 0 {
+			selected = append(selected, coin)
+			continue
+		}
+		for _, filter := range filters {
+			if filter == strconv.FormatUint(coin.Id, 10) || strings.EqualFold(filter, coin.Tag) || strings.EqualFold(filter, coin.Algorithm) {
+				selected = append(selected, coin)
+				break
+			}
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Id < selected[j].Id
+	})
+	return selected
+}
+
+func getCoins(filters []string) ([]Coin, error) {
+	wtmClient := NewWhatToMineClient(nil, baseurl, userAgent)
+	wtmClient.SetDebug(debug)
+	coins, err := wtmClient.GetCoins(newCoinsRequest())
+	if err != nil {
+		return nil, err
+	}
+	return selectCoins(coins, filters), nil
+}
+
+// DiscoverCoins is a DiscoveryItemHandlerFunc for key `wtm.discovery` which returns JSON
+// encoded discovery data for the coins selected by id, tag or algorithm
+func DiscoverCoins(request []string) (lld.DiscoveryData, error) {
+	coins, err := getCoins(request)
+	if err != nil {
+		return nil, err
+	}
+	d := make(lld.DiscoveryData, 0, len(coins))
+	for _, coin := range coins {
+		item := make(lld.DiscoveryItem, 0)
+		item["ID"] = strconv.FormatUint(coin.Id, 10)
+		item["TAG"] = coin.Tag
+		item["ALGO"] = coin.Algorithm
+		item["NAME"] = coin.Name
+		d = append(d, item)
+	}
+	return d, nil
+}
+
+// QueryCoins is a StringItemHandlerFunc for key `wtm.coins` which returns the coins selected by
+// id, tag or algorithm as JSON keyed by the coin id
+func QueryCoins(request []string) (string, error) {
+	coins, err := getCoins(request)
+	if err != nil {
+		return "", err
+	}
+	output := make(map[string]CoinStatus, len(coins))
+	for _, coin := range coins {
+		status := CoinStatus{
+			Id:                 coin.Id,
+			Name:               coin.Name,
+			Tag:                coin.Tag,
+			Algorithm:          coin.Algorithm,
+			BlockTime:          coin.BlockTime,
+			BlockReward:        coin.BlockReward,
+			Difficulty:         coin.Difficulty,
+			NetHash:            coin.NetHash,
+			ExchangeRate:       coin.ExchangeRate,
+			ExchangeRate24:     coin.ExchangeRate24,
+			EstimatedRewards:   coin.EstimatedRewards,
+			EstimatedRewards24: coin.EstimatedRewards24,
+			BtcRevenue:         coin.BtcRevenue,
+			BtcRevenue24:       coin.BtcRevenue24,
+			Profitability:      coin.Profitability,
+			Profitability24:    coin.Profitability24,
+			Timestamp:          coin.Timestamp.Unix(),
+		}
+		if coin.Lagging {
+			status.Lagging = 1
+		}
+		output[strconv.FormatUint(coin.Id, 10)] = status
+	}
+	b, err := json.Marshal(output)
+	return string(b), err
+}
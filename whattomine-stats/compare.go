@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Elbandi/zabbix-checker/common/nicehashv2"
+)
+
+// nicehashTimeout limits a whole nicehash api request
+const nicehashTimeout = 30 * time.Second
+
+const (
+	MarketCoin     = "coin"
+	MarketNicehash = "nicehash"
+)
+
+var (
+	ErrInvalidProfile  = errors.New("Invalid rig profile line")
+	ErrInvalidHashRate = errors.New("Invalid hashrate format")
+)
+
+// RigAlgo is a line of the rig profile: the whattomine algorithm, the
+// nicehash algorithm, the hashrate and the power draw in watt
+type RigAlgo struct {
+	Algorithm         string
+	NicehashAlgorithm string
+	HashRate          float64
+	Power             float64
+}
+
+// Comparison is the output of the compare action, the revenues, costs and
+// profits are in BTC per day
+type Comparison struct {
+	Algorithm         string  `json:"algorithm"`
+	NicehashAlgorithm string  `json:"nicehash_algorithm"`
+	HashRate          float64 `json:"hashrate"`
+	Power             float64 `json:"power"`
+	PowerCost         float64 `json:"power_cost"`
+	BestCoin          string  `json:"best_coin"`
+	BestCoinTag       string  `json:"best_coin_tag"`
+	BestCoinId        uint64  `json:"best_coin_id"`
+	CoinRevenue       float64 `json:"coin_revenue"`
+	CoinProfit        float64 `json:"coin_profit"`
+	NicehashRevenue   float64 `json:"nicehash_revenue"`
+	NicehashProfit    float64 `json:"nicehash_profit"`
+	BestMarket        string  `json:"best_market"`
+	Margin            float64 `json:"margin"`
+	Switch            int     `json:"switch"`
+}
+
+var hashRateSuffixes = map[byte]float64{
+	'k': 1e3, 'K': 1e3, 'M': 1e6, 'G': 1e9, 'T': 1e12, 'P': 1e15, 'E': 1e18,
+}
+
+// ParseHashRate parses a hashrate in H/s with an optional SI prefix, like
+// 60M, 110TH/s or 1500
+func ParseHashRate(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "/s")
+	s = strings.TrimSuffix(s, "H")
+	multiplier := 1.0
+	if len(s) > 0 {
+		if m, ok := hashRateSuffixes[s[len(s)-1]]; ok {
+			multiplier = m
+			s = s[:len(s)-1]
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || v < 0 {
+		return 0, ErrInvalidHashRate
+	}
+	return v * multiplier, nil
+}
+
+// ReadRigProfile reads the WTMALGO|NICEHASHALGO|HASHRATE|POWER lines of a
+// rig profile, the nicehash algorithm may be empty
+func ReadRigProfile(fileName string) ([]RigAlgo, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var profile []RigAlgo
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidProfile, line)
+		}
+		hashRate, err := ParseHashRate(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, line)
+		}
+		power, err := strconv.ParseFloat(strings.TrimSpace(fields[3]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidProfile, line)
+		}
+		profile = append(profile, RigAlgo{
+			Algorithm:         strings.TrimSpace(fields[0]),
+			NicehashAlgorithm: strings.ToUpper(strings.TrimSpace(fields[1])),
+			HashRate:          hashRate,
+			Power:             power,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// CoinRevenue returns the BTC a day mined by hashRate H/s, the units of the
+// whattomine hashrates differ by algorithm, the network hashrate is in H/s
+func CoinRevenue(coin Coin, hashRate float64) float64 {
+	if coin.NetHash <= 0 || coin.BlockTime <= 0 {
+		return 0.00
+	}
+	coins := hashRate / coin.NetHash * 86400 / coin.BlockTime * coin.BlockReward
+	return coins * coin.ExchangeRate * (1 - poolFee/100)
+}
+
+// Compare is a StringItemHandlerFunc for key `wtm.compare` which returns the best coin and the
+// profit of selling the hashpower on nicehash for every algo of the rig profile as JSON
+func Compare(request []string) (string, error) {
+	profile, err := ReadRigProfile(request[0])
+	if err != nil {
+		return "", err
+	}
+	wtmClient := NewWhatToMineClient(nil, baseurl, userAgent)
+	wtmClient.SetDebug(debug)
+	coins, err := wtmClient.GetCoins(&coinsRequest{})
+	if err != nil {
+		return "", err
+	}
+
+	nhClient := nicehashv2.NewClient(&http.Client{Timeout: nicehashTimeout}, nicehashBase, "", "", "", userAgent)
+	nhClient.SetDebug(debug)
+	// the power cost is in USD, the revenues are in BTC
+	price := btcPrice
+	if powerCost > 0 && price <= 0 {
+		if price, err = nhClient.GetExchangeRate("BTC", "USD"); err != nil {
+			return "", fmt.Errorf("Failed to get the btc price: %w", err)
+		}
+	}
+	algorithms, err := nhClient.GetAlgorithms()
+	if err != nil {
+		return "", err
+	}
+	miningAlgorithms, err := nhClient.GetMiningAlgorithms()
+	if err != nil {
+		return "", err
+	}
+	paying := make(map[string]float64)
+	for _, algo := range algorithms {
+		paying[algo.Algorithm] = float64(algo.Paying)
+	}
+	factors := make(map[string]float64)
+	for _, algo := range miningAlgorithms {
+		factors[algo.Algorithm] = float64(algo.MarketFactor)
+	}
+
+	output := make([]Comparison, 0, len(profile))
+	for _, rig := range profile {
+		c := Comparison{
+			Algorithm:         rig.Algorithm,
+			NicehashAlgorithm: rig.NicehashAlgorithm,
+			HashRate:          rig.HashRate,
+			Power:             rig.Power,
+		}
+		if powerCost > 0 {
+			c.PowerCost = rig.Power * 24 / 1000 * powerCost / price
+		}
+		for name, coin := range coins {
+			if !strings.EqualFold(coin.Algorithm, rig.Algorithm) || coin.Lagging {
+				continue
+			}
+			if revenue := CoinRevenue(coin, rig.HashRate); len(c.BestCoin) == 0 || revenue > c.CoinRevenue {
+				c.BestCoin = name
+				c.BestCoinTag = coin.Tag
+				c.BestCoinId = coin.Id
+				c.CoinRevenue = revenue
+			}
+		}
+		if len(rig.NicehashAlgorithm) > 0 {
+			factor := factors[rig.NicehashAlgorithm]
+			if factor <= 0 {
+				return "", fmt.Errorf("Unknown nicehash algorithm: %s", rig.NicehashAlgorithm)
+			}
+			c.NicehashRevenue = paying[rig.NicehashAlgorithm] * rig.HashRate / factor * (1 - nicehashFee/100)
+		}
+		c.CoinProfit = c.CoinRevenue - c.PowerCost
+		c.NicehashProfit = c.NicehashRevenue - c.PowerCost
+		c.Margin = c.CoinProfit - c.NicehashProfit
+		if len(rig.NicehashAlgorithm) > 0 {
+			c.BestMarket = MarketNicehash
+		}
+		if len(c.BestCoin) > 0 && (c.Margin > 0 || len(rig.NicehashAlgorithm) == 0) {
+			c.BestMarket = MarketCoin
+			// switching pays only if the hashpower is sold now
+			if len(rig.NicehashAlgorithm) > 0 {
+				c.Switch = 1
+			}
+		}
+		output = append(output, c)
+	}
+	b, err := json.Marshal(output)
+	return string(b), err
+}
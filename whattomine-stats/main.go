@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/Elbandi/zabbix-checker/common/nicehashv2"
 	"golang.org/x/net/proxy"
 	"crypto/tls"
 	"errors"
@@ -18,11 +19,25 @@ const defaultUserAgent = "wtm-stats/1.0"
 
 var (
 	// flags
-	debug     bool
-	output    string
-	userAgent string
+	debug        bool
+	output       string
+	userAgent    string
+	baseurl      string
+	nicehashBase string
+	hashRate     float64
+	power        float64
+	poolFee      float64
+	nicehashFee  float64
+	powerCost    float64
+	btcPrice     float64
 )
 
+// newCoinsRequest returns the hashrate, power, fee and cost parameters of the
+// flags
+func newCoinsRequest() *coinsRequest {
+	return &coinsRequest{HashRate: param(hashRate), Power: param(power), PoolFee: param(poolFee), PowerCost: param(powerCost)}
+}
+
 // ExchangeRate is a DoubleItemHandlerFunc for key `wtm.exchange_rate` which returns the current exchange rate
 // for coin.
 func ExchangeRate(request []string) (float64, error) {
@@ -31,9 +46,9 @@ func ExchangeRate(request []string) (float64, error) {
 		return 0.00, errors.New("Invalid coinid format")
 	}
 
-	wtmClient := NewWhatToMineClient(nil, BASE, userAgent)
+	wtmClient := NewWhatToMineClient(nil, baseurl, userAgent)
 	wtmClient.SetDebug(debug)
-	status, err := wtmClient.GetCoin(coinId, 1000000, 0, 0)
+	status, err := wtmClient.GetCoin(coinId, newCoinsRequest())
 	if err != nil {
 		return 0.00, err
 	}
@@ -48,9 +63,9 @@ func ExchangeRate24(request []string) (float64, error) {
 		return 0.00, errors.New("Invalid coinid format")
 	}
 
-	wtmClient := NewWhatToMineClient(nil, BASE, userAgent)
+	wtmClient := NewWhatToMineClient(nil, baseurl, userAgent)
 	wtmClient.SetDebug(debug)
-	status, err := wtmClient.GetCoin(coinId, 1000000, 0, 0)
+	status, err := wtmClient.GetCoin(coinId, newCoinsRequest())
 	if err != nil {
 		return 0.00, err
 	}
@@ -65,9 +80,9 @@ func EstimatedRewards(request []string) (float64, error) {
 		return 0.00, errors.New("Invalid coinid format")
 	}
 
-	wtmClient := NewWhatToMineClient(nil, BASE, userAgent)
+	wtmClient := NewWhatToMineClient(nil, baseurl, userAgent)
 	wtmClient.SetDebug(debug)
-	status, err := wtmClient.GetCoin(coinId, 1000000, 0, 0)
+	status, err := wtmClient.GetCoin(coinId, newCoinsRequest())
 	if err != nil {
 		return 0.00, err
 	}
@@ -81,9 +96,9 @@ func BtcRevenue(request []string) (float64, error) {
 		return 0.00, errors.New("Invalid coinid format")
 	}
 
-	wtmClient := NewWhatToMineClient(nil, BASE, userAgent)
+	wtmClient := NewWhatToMineClient(nil, baseurl, userAgent)
 	wtmClient.SetDebug(debug)
-	status, err := wtmClient.GetCoin(coinId, 1000000, 0, 0)
+	status, err := wtmClient.GetCoin(coinId, newCoinsRequest())
 	if err != nil {
 		return 0.00, err
 	}
@@ -95,6 +110,14 @@ func main() {
 	flag.BoolVar(&debug, "debug", false, "enable request/response dump")
 	flag.StringVar(&output, "output", "", "output the result to file")
 	flag.StringVar(&userAgent, "user-agent", defaultUserAgent, "http client user agent")
+	flag.StringVar(&baseurl, "base", BASE, "whattomine base url")
+	flag.StringVar(&nicehashBase, "nicehash-base", nicehashv2.DefaultBaseURL, "nicehash api v2 base url")
+	flag.Float64Var(&hashRate, "hashrate", 1000000, "hashrate in the unit of the coin algorithm")
+	flag.Float64Var(&power, "power", 0, "power draw in watt")
+	flag.Float64Var(&poolFee, "fee", 0, "pool fee in percent")
+	flag.Float64Var(&nicehashFee, "nicehash-fee", 2, "nicehash fee of the sold hashpower in percent")
+	flag.Float64Var(&powerCost, "cost", 0, "power cost in USD/kWh")
+	flag.Float64Var(&btcPrice, "btc-price", 0, "BTC price in USD for the power cost instead of the nicehash exchange rate")
 	flag.Parse()
 	log.SetOutput(os.Stderr)
 
@@ -177,11 +200,46 @@ func main() {
 		default:
 			log.Fatalf("Usage: %s btc_revenue COIN", os.Args[0])
 		}
+	case "discovery":
+		if v, err := DiscoverCoins(flag.Args()[1:]); err != nil {
+			log.Fatalf("Error: %s", err.Error())
+		} else {
+			if output != "" {
+				ioutil.WriteFile(output, []byte(v.Json()), 0644)
+			} else {
+				fmt.Print(v.Json())
+			}
+		}
+	case "coins":
+		if v, err := QueryCoins(flag.Args()[1:]); err != nil {
+			log.Fatalf("Error: %s", err.Error())
+		} else {
+			if output != "" {
+				ioutil.WriteFile(output, []byte(v), 0644)
+			} else {
+				fmt.Print(v)
+			}
+		}
+	case "compare":
+		switch flag.NArg() {
+		case 2:
+			if v, err := Compare(flag.Args()[1:]); err != nil {
+				log.Fatalf("Error: %s", err.Error())
+			} else {
+				if output != "" {
+					ioutil.WriteFile(output, []byte(v), 0644)
+				} else {
+					fmt.Print(v)
+				}
+			}
+		default:
+			log.Fatalf("Usage: %s compare PROFILE", os.Args[0])
+		}
 	default:
 		log.Fatal("You must specify one of the following action: " +
-		//			"'discovery', " +
+			"'discovery', 'coins', " +
 			"'exchange_rate', 'exchange_rate24', " +
-			"'estimated_rewards' or 'btc_revenue'.")
+			"'estimated_rewards', 'btc_revenue' or 'compare'.")
 
 	}
 
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"strings"
 	"strconv"
 	"time"
@@ -117,6 +118,7 @@ func (client WhatToMineClient) SetDebug(debug bool) {
 // Struct type that represents one coin from www.whattomine.com
 type Coin struct {
 	Id                 uint64    `json:"id"`
+	Name               string    `json:"name"`
 	Tag                string    `json:"tag"`
 	Algorithm          string    `json:"algorithm"`
 	BlockTime          float64   `json:"block_time"`
@@ -145,9 +147,12 @@ func (t *Coin) UnmarshalJSON(data []byte) error {
 	var err error
 	type Alias Coin
 	aux := &struct {
-		BlockTime        json.Number `json:"block_time"`
-		EstimatedRewards string      `json:"estimated_rewards"`
-		Timestamp        int64       `json:"timestamp"`
+		BlockTime          json.Number `json:"block_time"`
+		EstimatedRewards   string      `json:"estimated_rewards"`
+		EstimatedRewards24 string      `json:"estimated_rewards24"`
+		BtcRevenue         string      `json:"btc_revenue"`
+		BtcRevenue24       string      `json:"btc_revenue24"`
+		Timestamp          int64       `json:"timestamp"`
 		*Alias
 	}{
 		Alias: (*Alias)(t),
@@ -156,20 +161,43 @@ func (t *Coin) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	t.Timestamp = time.Unix(aux.Timestamp, 0)
-	t.EstimatedRewards, err = strconv.ParseFloat(strings.Replace(aux.EstimatedRewards, ",", "", -1), 64)
-	if err != nil {
+	if t.EstimatedRewards, err = parseNumber(aux.EstimatedRewards); err != nil {
+		return err
+	}
+	if t.EstimatedRewards24, err = parseNumber(aux.EstimatedRewards24); err != nil {
+		return err
+	}
+	if t.BtcRevenue, err = parseNumber(aux.BtcRevenue); err != nil {
+		return err
+	}
+	if t.BtcRevenue24, err = parseNumber(aux.BtcRevenue24); err != nil {
 		return err
 	}
 	t.BlockTime, err = aux.BlockTime.Float64()
 	return err
 }
 
+// parseNumber parses a string encoded number of the api, the big ones have
+// thousands separators like 1,234.5
+func parseNumber(s string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(s, ",", "", -1), 64)
+}
+
+// param is a query parameter without exponent, the default formatting sends
+// 1e+06 for a million
+type param float64
+
+func (p param) EncodeValues(key string, v *url.Values) error {
+	v.Set(key, strconv.FormatFloat(float64(p), 'f', -1, 64))
+	return nil
+}
+
 type coinsRequest struct {
-	HashRate     float64 `url:"hr,omitempty"`
-	Power        float64 `url:"p,omitempty"`
-	PoolFee      float64 `url:"fee,omitempty"`
-	PowerCost    float64 `url:"cost,omitempty"`
-	HardwareCost float64 `url:"hcost,omitempty"`
+	HashRate     param `url:"hr,omitempty"`
+	Power        param `url:"p,omitempty"`
+	PoolFee      param `url:"fee,omitempty"`
+	PowerCost    param `url:"cost,omitempty"`
+	HardwareCost param `url:"hcost,omitempty"`
 }
 type Coins map[string]Coin
 
@@ -177,9 +205,8 @@ type coinsResponse struct {
 	Coins Coins `json:"coins"`
 }
 
-func (client *WhatToMineClient) GetCoins(hashRate, power, poolFee float64) (Coins, error) {
+func (client *WhatToMineClient) GetCoins(req *coinsRequest) (Coins, error) {
 	response := coinsResponse{}
-	req := &coinsRequest{HashRate: hashRate, Power: power, PoolFee: poolFee}
 	_, err := client.sling.New().Get("coins.json").QueryStruct(req).ReceiveSuccess(&response)
 	if err != nil {
 		return nil, err
@@ -187,8 +214,7 @@ func (client *WhatToMineClient) GetCoins(hashRate, power, poolFee float64) (Coin
 	return response.Coins, nil
 }
 
-func (client *WhatToMineClient) GetCoin(id uint64, hashRate, power, poolFee float64) (coin Coin, err error) {
-	req := &coinsRequest{HashRate: hashRate, Power: power, PoolFee: poolFee}
+func (client *WhatToMineClient) GetCoin(id uint64, req *coinsRequest) (coin Coin, err error) {
 	_, err = client.sling.New().Get("coins/" + strconv.FormatUint(id, 10) + ".json").QueryStruct(req).ReceiveSuccess(&coin)
 	return
 }
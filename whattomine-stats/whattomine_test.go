@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetCoins(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/coins.json" {
+			http.NotFound(w, r)
+			return
+		}
+		// the api answers with text/html
+		w.Header().Set("Content-Type", "text/html")
+		http.ServeFile(w, r, "testdata/coins.json")
+	}))
+	defer s.Close()
+
+	coins, err := NewWhatToMineClient(s.Client(), s.URL+"/", "test").GetCoins(&coinsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coins) != 2 {
+		t.Fatalf("got %d coins, want 2", len(coins))
+	}
+	doge := coins["Dogecoin"]
+	if doge.EstimatedRewards != 1234.56 || doge.EstimatedRewards24 != 1201.2 || doge.BtcRevenue != 0.00234 || doge.BtcRevenue24 != 1000.5 {
+		t.Errorf("got %+v", doge)
+	}
+	if btc := coins["Bitcoin"]; btc.BlockTime != 602 || btc.Timestamp.Unix() != 1729000000 {
+		t.Errorf("got %+v", btc)
+	}
+}